@@ -0,0 +1,154 @@
+package twitchext
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// appTokenURL the Twitch OAuth client credentials endpoint
+// https://dev.twitch.tv/docs/authentication/getting-tokens-oauth/#client-credentials-grant-flow
+const appTokenURL = "https://id.twitch.tv/oauth2/token"
+
+// appTokenExpiryMargin how long before expiring an app access token is renewed
+const appTokenExpiryMargin = time.Minute
+
+// ErrMissingAppAccessToken is returned when a Helix endpoint requiring an
+// app access token is called without Options.AppAccessToken or Options.ClientSecret.
+var ErrMissingAppAccessToken = errors.New("missing app access token, set Options.AppAccessToken or Options.ClientSecret")
+
+// appToken an app access token, either provided by the
+// caller or requested using the client credentials grant.
+type appToken struct {
+	static       string
+	clientSecret string
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+type appTokenResponse struct {
+	AccessToken string `json:"access_token"`
+	ExpiresIn   int    `json:"expires_in"`
+	TokenType   string `json:"token_type"`
+}
+
+// appAccessToken returns the app access token of the client, requesting
+// a new token once the previous one expires when a client secret is set.
+func (t *Twitch) appAccessToken() (token string, err error) {
+	a := t.appToken
+	if a.static != "" {
+		token = a.static
+		return
+	}
+	if a.clientSecret == "" {
+		err = ErrMissingAppAccessToken
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.token != "" && time.Now().Before(a.expires) {
+		token = a.token
+		return
+	}
+
+	form := url.Values{}
+	form.Set("client_id", t.ClientID)
+	form.Set("client_secret", a.clientSecret)
+	form.Set("grant_type", "client_credentials")
+
+	resp, err := t.client.Post(appTokenURL, "application/x-www-form-urlencoded", strings.NewReader(form.Encode()))
+	if err != nil {
+		err = fmt.Errorf("failed to request app access token err:%s", err)
+		return
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return
+	}
+	if resp.StatusCode != http.StatusOK {
+		err = fmt.Errorf(
+			"failed to request app access token err:%w",
+			&APIError{StatusCode: resp.StatusCode, Status: resp.Status, Body: string(body)},
+		)
+		return
+	}
+
+	data := &appTokenResponse{}
+	err = json.Unmarshal(body, data)
+	if err != nil {
+		err = fmt.Errorf("failed to decode app access token err:%s", err)
+		return
+	}
+	if data.AccessToken == "" {
+		err = fmt.Errorf("app access token response missing access_token")
+		return
+	}
+
+	a.token = data.AccessToken
+	a.expires = time.Now().Add(time.Duration(data.ExpiresIn)*time.Second - appTokenExpiryMargin)
+	token = a.token
+
+	return
+}
+
+// invalidateAppAccessToken discards a requested app access token
+// rejected by Twitch, returning whether a new token can be requested.
+func (t *Twitch) invalidateAppAccessToken(token string) bool {
+	a := t.appToken
+	if a.static != "" || a.clientSecret == "" {
+		return false
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.token == token {
+		a.token = ""
+	}
+	return true
+}
+
+// doWithAppToken sends a request authorised by the app access token,
+// retrying once with a new token when the token is rejected.
+func (t *Twitch) doWithAppToken(
+	method string,
+	url string,
+	b []byte,
+	q url.Values,
+) (
+	data []byte,
+	headers http.Header,
+	err error,
+) {
+	for attempt := 0; ; attempt++ {
+		var token string
+		token, err = t.appAccessToken()
+		if err != nil {
+			return
+		}
+
+		data, headers, err = t.doWithToken(method, url, token, b, q)
+
+		var apiErr *APIError
+		if attempt == 0 &&
+			errors.As(err, &apiErr) &&
+			apiErr.StatusCode == http.StatusUnauthorized &&
+			t.invalidateAppAccessToken(token) {
+			continue
+		}
+
+		return
+	}
+}
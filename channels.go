@@ -5,8 +5,14 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
+	"strconv"
+	"strings"
 )
 
+// maxLiveChannelsPageSize the largest page of live
+// channels returned by the Helix endpoint.
+const maxLiveChannelsPageSize = 100
+
 // ExtensionEnabledChannels Response type of the getLiveChannelsWithExtensionEnabled
 type ExtensionEnabledChannels struct {
 	ResponseCommon
@@ -15,18 +21,135 @@ type ExtensionEnabledChannels struct {
 }
 
 // Channel A struct representative of an individual
-// channel returned by getLiveChannelsWithExtensionEnabled
+// channel returned by getLiveChannelsWithExtensionEnabled.
+// Both the Helix and legacy response fields are decoded, channels
+// are encoded in the legacy shape. GameID is only reported by Helix.
 type Channel struct {
 	Game     string `json:"game"`
 	ID       string `json:"id"`
 	Username string `json:"username"`
 	Title    string `json:"title"`
-	Viewers  string `json:"view_count"`
+	Viewers  int    `json:"view_count,string"`
+	GameID   string `json:"game_id,omitempty"`
+}
+
+// channelFields the fields of a live channel across
+// the Helix and legacy endpoint families.
+type channelFields struct {
+	BroadcasterID   string          `json:"broadcaster_id"`
+	BroadcasterName string          `json:"broadcaster_name"`
+	GameID          string          `json:"game_id"`
+	GameName        string          `json:"game_name"`
+	Title           string          `json:"title"`
+	ViewCount       json.RawMessage `json:"view_count"`
+
+	// legacy fields
+	ID       string `json:"id"`
+	Username string `json:"username"`
+	Game     string `json:"game"`
+}
+
+// UnmarshalJSON decodes a channel from either endpoint family,
+// accepting viewer counts encoded as numbers or strings.
+func (c *Channel) UnmarshalJSON(data []byte) (err error) {
+	var fields channelFields
+	err = json.Unmarshal(data, &fields)
+	if err != nil {
+		return
+	}
+
+	viewers, err := parseViewCount(fields.ViewCount)
+	if err != nil {
+		return
+	}
+
+	*c = Channel{
+		ID:       firstNonEmpty(fields.BroadcasterID, fields.ID),
+		Username: firstNonEmpty(fields.BroadcasterName, fields.Username),
+		GameID:   fields.GameID,
+		Game:     firstNonEmpty(fields.GameName, fields.Game),
+		Title:    fields.Title,
+		Viewers:  viewers,
+	}
+
+	return
+}
+
+func parseViewCount(raw json.RawMessage) (viewers int, err error) {
+	s := strings.Trim(string(raw), `"`)
+	if s == "" || s == "null" {
+		return
+	}
+
+	viewers, err = strconv.Atoi(s)
+	if err != nil {
+		err = fmt.Errorf("invalid view_count:%s err:%s", raw, err)
+	}
+
+	return
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// streamsResponse the body of the Helix Get Streams endpoint
+type streamsResponse struct {
+	Data []struct {
+		UserID      string `json:"user_id"`
+		ViewerCount int    `json:"viewer_count"`
+	} `json:"data"`
 }
 
-// GetLiveChannelsWithExtensionEnabled Retrieve all live
-// twitch channels which have the extension enabled.
-// https://dev.twitch.tv/docs/extensions/reference/#get-live-channels-with-extension-activated
+// liveChannelsResponse the body of the Helix and legacy live channels endpoints
+type liveChannelsResponse struct {
+	Channels   []*Channel      `json:"channels"`
+	Cursor     string          `json:"cursor"`
+	Data       []*Channel      `json:"data"`
+	Pagination json.RawMessage `json:"pagination"`
+}
+
+// cursor the pagination cursor of the response, Helix documents
+// pagination as a string though other endpoints return an object.
+func (r *liveChannelsResponse) cursor() string {
+	if r.Cursor != "" || len(r.Pagination) == 0 {
+		return r.Cursor
+	}
+
+	var cursor string
+	if json.Unmarshal(r.Pagination, &cursor) == nil {
+		return cursor
+	}
+
+	var pagination struct {
+		Cursor string `json:"cursor"`
+	}
+	json.Unmarshal(r.Pagination, &pagination)
+
+	return pagination.Cursor
+}
+
+// GetLiveChannels Retrieve a page of the live twitch
+// channels which have the client's extension enabled.
+func (t *Twitch) GetLiveChannels(bookmark string) (channels *ExtensionEnabledChannels, err error) {
+	return t.GetLiveChannelsWithExtensionEnabled(t.ClientID, bookmark)
+}
+
+// GetLiveChannelsWithExtensionEnabled Retrieve all live twitch channels
+// which have the extension enabled, an empty extension ID defaults to the
+// client's extension. Other published extensions may also be queried.
+// Channels are requested from the Helix endpoint when the client
+// was created with Options.AppAccessToken or Options.ClientSecret,
+// which the Helix endpoint requires, otherwise from the legacy
+// endpoint, unless Options.LiveChannelsAPI selects a family.
+// The Helix endpoint does not report viewers, see
+// LiveChannelsOptions.IncludeViewers.
+// https://dev.twitch.tv/docs/api/reference/#get-extension-live-channels
 func (t *Twitch) GetLiveChannelsWithExtensionEnabled(
 	extensionId string,
 	bookmark string,
@@ -34,28 +157,100 @@ func (t *Twitch) GetLiveChannelsWithExtensionEnabled(
 	channels *ExtensionEnabledChannels,
 	err error,
 ) {
-	addr := fmt.Sprintf(
-		"https://api.twitch.tv/extensions/%s/live_activated_channels",
-		extensionId,
-	)
+	if extensionId == "" {
+		extensionId = t.ClientID
+	}
 
+	var (
+		resp    []byte
+		headers http.Header
+	)
 	q := url.Values{}
 
-	if bookmark != "" {
-		q.Set("cursor", bookmark)
-	}
+	switch t.liveChannelsAPI {
+	case LegacyAPI:
+		addr := fmt.Sprintf(
+			"https://api.twitch.tv/extensions/%s/live_activated_channels",
+			extensionId,
+		)
+
+		if bookmark != "" {
+			q.Set("cursor", bookmark)
+		}
 
-	resp, headers, err := t.do(http.MethodGet, addr, nil, nil, q)
+		// the legacy endpoint is authorised by the Client-ID header alone
+		resp, headers, err = t.do(http.MethodGet, addr, nil, nil, q)
+	default:
+		addr := "https://api.twitch.tv/helix/extensions/live"
+
+		q.Set("extension_id", extensionId)
+		q.Set("first", strconv.Itoa(maxLiveChannelsPageSize))
+		if bookmark != "" {
+			q.Set("after", bookmark)
+		}
+
+		resp, headers, err = t.doWithAppToken(http.MethodGet, addr, nil, q)
+	}
 	if err != nil {
 		return
 	}
 
-	channels = &ExtensionEnabledChannels{}
-	err = json.Unmarshal(resp, &channels)
+	body := &liveChannelsResponse{}
+	err = json.Unmarshal(resp, body)
 	if err != nil {
 		return
 	}
+
+	channels = &ExtensionEnabledChannels{
+		Channels: body.Channels,
+		Bookmark: body.cursor(),
+	}
+	if body.Data != nil {
+		channels.Channels = body.Data
+	}
 	channels.Headers = headers
 
 	return
 }
+
+// fillViewers sets the viewers of the channels from the Helix Get Streams
+// endpoint, channels whose stream has since ended report zero viewers.
+// https://dev.twitch.tv/docs/api/reference/#get-streams
+func (t *Twitch) fillViewers(channels []*Channel) (headers http.Header, err error) {
+	viewers := map[string]int{}
+	for start := 0; start < len(channels); start += maxLiveChannelsPageSize {
+		end := start + maxLiveChannelsPageSize
+		if end > len(channels) {
+			end = len(channels)
+		}
+
+		q := url.Values{}
+		q.Set("first", strconv.Itoa(maxLiveChannelsPageSize))
+		for _, channel := range channels[start:end] {
+			q.Add("user_id", channel.ID)
+		}
+
+		var resp []byte
+		resp, headers, err = t.doWithAppToken(http.MethodGet, "https://api.twitch.tv/helix/streams", nil, q)
+		if err != nil {
+			err = fmt.Errorf("failed to get stream viewers err:%w", err)
+			return
+		}
+
+		body := &streamsResponse{}
+		err = json.Unmarshal(resp, body)
+		if err != nil {
+			err = fmt.Errorf("failed to decode stream viewers err:%s", err)
+			return
+		}
+		for _, stream := range body.Data {
+			viewers[stream.UserID] = stream.ViewerCount
+		}
+	}
+
+	for _, channel := range channels {
+		channel.Viewers = viewers[channel.ID]
+	}
+
+	return
+}
@@ -0,0 +1,180 @@
+package twitchext
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultLiveChannelsRetries   = 3
+	defaultLiveChannelsRetryWait = time.Second
+	maxLiveChannelsRetryWait     = time.Minute
+)
+
+// ErrStopIteration may be returned by a LiveChannelFunc
+// to stop iterating without EachLiveChannel failing.
+var ErrStopIteration = errors.New("stop iteration")
+
+// LiveChannelFunc is called with each live channel, returning
+// an error stops the iteration.
+type LiveChannelFunc func(channel *Channel) error
+
+// LiveChannelsOptions optional parameters used
+// when iterating every page of live channels
+type LiveChannelsOptions struct {
+	// ExtensionID the extension whose live channels are listed,
+	// defaults to the client ID.
+	ExtensionID string
+	// MaxResults stops the iteration once this many
+	// channels have been returned, zero is unlimited.
+	MaxResults int
+	// RateLimitRetries the number of times a rate limited
+	// page is retried, defaults to 3.
+	RateLimitRetries int
+	// RetryWait the time to wait before retrying a rate limited page
+	// when Twitch does not report the rate limit reset, defaults to 1 second.
+	RetryWait time.Duration
+	// IncludeViewers requests the viewers of each page of channels from
+	// the Helix Get Streams endpoint, which the Helix live channels
+	// endpoint does not report, costing a second request for each page.
+	// The legacy endpoint always reports viewers.
+	IncludeViewers bool
+}
+
+// EachLiveChannel calls fn with every live channel with the extension
+// activated, requesting each page in turn until the bookmark is empty.
+// Rate limited pages are retried once the limit resets, and when a page
+// reports no remaining requests the next page waits for the reset.
+// The iteration stops when the context is cancelled, which is checked
+// before each request and while waiting on the rate limit.
+func (t *Twitch) EachLiveChannel(ctx context.Context, opts *LiveChannelsOptions, fn LiveChannelFunc) (err error) {
+	options := &LiveChannelsOptions{}
+	if opts != nil {
+		options = opts
+	}
+
+	retries := options.RateLimitRetries
+	if retries <= 0 {
+		retries = defaultLiveChannelsRetries
+	}
+
+	retryWait := options.RetryWait
+	if retryWait <= 0 {
+		retryWait = defaultLiveChannelsRetryWait
+	}
+
+	var (
+		bookmark string
+		count    int
+		wait     time.Duration
+	)
+	for {
+		var page *ExtensionEnabledChannels
+		for attempts := 0; ; attempts++ {
+			err = sleepContext(ctx, wait)
+			if err != nil {
+				return
+			}
+
+			page, err = t.GetLiveChannelsWithExtensionEnabled(options.ExtensionID, bookmark)
+			if err == nil && options.IncludeViewers && t.liveChannelsAPI != LegacyAPI {
+				var streamHeaders http.Header
+				streamHeaders, err = t.fillViewers(page.Channels)
+				// both endpoints share the rate limit of the app access token
+				if streamHeaders.Get("Ratelimit-Remaining") == "0" {
+					page.Headers = streamHeaders
+				}
+			}
+
+			var rateLimited *RateLimitError
+			if errors.As(err, &rateLimited) && attempts < retries {
+				wait = rateLimitWait(rateLimited.Headers, retryWait)
+				continue
+			}
+			if err != nil {
+				err = fmt.Errorf("failed to get live channels bookmark:%q err:%w", bookmark, err)
+				return
+			}
+			break
+		}
+
+		for _, channel := range page.Channels {
+			err = fn(channel)
+			if errors.Is(err, ErrStopIteration) {
+				err = nil
+				return
+			}
+			if err != nil {
+				return
+			}
+
+			count++
+			if options.MaxResults > 0 && count >= options.MaxResults {
+				return
+			}
+		}
+
+		if page.Bookmark == "" || page.Bookmark == bookmark {
+			return
+		}
+		bookmark = page.Bookmark
+
+		wait = 0
+		if page.Headers.Get("Ratelimit-Remaining") == "0" {
+			wait = rateLimitWait(page.Headers, 0)
+		}
+	}
+}
+
+// CollectLiveChannels returns every live channel with the extension
+// activated, up to MaxResults. The channels collected before an
+// error, including context cancellation, are returned with it.
+func (t *Twitch) CollectLiveChannels(ctx context.Context, opts *LiveChannelsOptions) (channels []*Channel, err error) {
+	err = t.EachLiveChannel(ctx, opts, func(channel *Channel) error {
+		channels = append(channels, channel)
+		return nil
+	})
+
+	return
+}
+
+// rateLimitWait the time until the rate limit reported by the
+// headers resets, or fallback when no reset is reported.
+func rateLimitWait(headers http.Header, fallback time.Duration) time.Duration {
+	reset, err := strconv.ParseInt(headers.Get("Ratelimit-Reset"), 10, 64)
+	if err != nil {
+		return fallback
+	}
+
+	wait := time.Until(time.Unix(reset, 0))
+	switch {
+	case wait < 0:
+		return 0
+	case wait > maxLiveChannelsRetryWait:
+		return maxLiveChannelsRetryWait
+	}
+	return wait
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if d <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,32 @@
+//go:build go1.23
+
+package twitchext
+
+import (
+	"context"
+	"iter"
+)
+
+// LiveChannels returns an iterator over every live channel with the
+// extension activated, see EachLiveChannel. An error ends the iteration
+// and is yielded with a nil channel, breaking out of the loop stops
+// requesting further pages.
+//
+//	for channel, err := range t.LiveChannels(ctx, nil) {
+//		if err != nil {
+//			return err
+//		}
+//	}
+func (t *Twitch) LiveChannels(ctx context.Context, opts *LiveChannelsOptions) iter.Seq2[*Channel, error] {
+	return func(yield func(*Channel, error) bool) {
+		err := t.EachLiveChannel(ctx, opts, func(channel *Channel) error {
+			if !yield(channel, nil) {
+				return ErrStopIteration
+			}
+			return nil
+		})
+		if err != nil {
+			yield(nil, err)
+		}
+	}
+}
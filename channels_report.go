@@ -0,0 +1,298 @@
+package twitchext
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// LiveAggregatorOptions optional parameters for the LiveAggregator
+type LiveAggregatorOptions struct {
+	// Retention the age after which snapshots are discarded,
+	// zero keeps every snapshot.
+	Retention time.Duration
+}
+
+// LiveAggregator records periodic snapshots of the live channels
+// with the extension activated and summarises them.
+// Snapshots are added with Add, SnapshotLiveChannels, or by
+// passing Add as a ChannelWatcher OnSnapshot callback.
+type LiveAggregator struct {
+	retention time.Duration
+
+	mu        sync.Mutex
+	snapshots []*liveSnapshot
+}
+
+type liveSnapshot struct {
+	at       time.Time
+	channels []liveSnapshotChannel
+}
+
+type liveSnapshotChannel struct {
+	id      string
+	game    string
+	gameID  string
+	viewers int
+}
+
+// LiveSnapshotPoint the live channels and viewers of a single snapshot
+type LiveSnapshotPoint struct {
+	At       time.Time `json:"at"`
+	Channels int       `json:"channels"`
+	Viewers  int       `json:"viewers"`
+}
+
+// GameSummary the live activity of a single game across snapshots.
+// Peaks are the largest concurrent values within a single snapshot.
+type GameSummary struct {
+	Game           string `json:"game"`
+	GameID         string `json:"game_id,omitempty"`
+	UniqueChannels int    `json:"unique_channels"`
+	PeakChannels   int    `json:"peak_channels"`
+	PeakViewers    int    `json:"peak_viewers"`
+}
+
+// LiveSummary a summary of the snapshots taken within a time range.
+// Helix snapshots only report viewers when taken with
+// LiveChannelsOptions.IncludeViewers, as SnapshotLiveChannels does.
+type LiveSummary struct {
+	From            time.Time            `json:"from"`
+	To              time.Time            `json:"to"`
+	Snapshots       int                  `json:"snapshots"`
+	UniqueChannels  int                  `json:"unique_channels"`
+	PeakChannels    int                  `json:"peak_channels"`
+	PeakChannelsAt  time.Time            `json:"peak_channels_at"`
+	PeakViewers     int                  `json:"peak_viewers"`
+	PeakViewersAt   time.Time            `json:"peak_viewers_at"`
+	AverageChannels float64              `json:"average_channels"`
+	AverageViewers  float64              `json:"average_viewers"`
+	Games           []*GameSummary       `json:"games"`
+	Series          []*LiveSnapshotPoint `json:"series"`
+}
+
+// NewLiveAggregator create an empty aggregator
+func NewLiveAggregator(opts ...*LiveAggregatorOptions) *LiveAggregator {
+	a := &LiveAggregator{}
+
+	if len(opts) > 0 && opts[0] != nil {
+		a.retention = opts[0].Retention
+	}
+
+	return a
+}
+
+// Add records the channels live at the given time
+func (a *LiveAggregator) Add(at time.Time, channels []*Channel) {
+	snapshot := &liveSnapshot{
+		at:       at,
+		channels: make([]liveSnapshotChannel, 0, len(channels)),
+	}
+
+	seen := map[string]bool{}
+	for _, channel := range channels {
+		if seen[channel.ID] {
+			continue
+		}
+		seen[channel.ID] = true
+
+		snapshot.channels = append(snapshot.channels, liveSnapshotChannel{
+			id:      channel.ID,
+			game:    channel.Game,
+			gameID:  channel.GameID,
+			viewers: channel.Viewers,
+		})
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	i := sort.Search(len(a.snapshots), func(i int) bool {
+		return a.snapshots[i].at.After(at)
+	})
+	a.snapshots = append(a.snapshots, nil)
+	copy(a.snapshots[i+1:], a.snapshots[i:])
+	a.snapshots[i] = snapshot
+
+	if a.retention > 0 {
+		cutoff := a.snapshots[len(a.snapshots)-1].at.Add(-a.retention)
+		expired := sort.Search(len(a.snapshots), func(i int) bool {
+			return !a.snapshots[i].at.Before(cutoff)
+		})
+		a.snapshots = a.snapshots[expired:]
+	}
+}
+
+// SnapshotLiveChannels requests every live channel, including
+// their viewers, and records them within the aggregator at the current time.
+func (t *Twitch) SnapshotLiveChannels(ctx context.Context, aggregator *LiveAggregator, opts *LiveChannelsOptions) (err error) {
+	options := LiveChannelsOptions{}
+	if opts != nil {
+		options = *opts
+	}
+	options.MaxResults = 0
+	options.IncludeViewers = true
+
+	channels, err := t.CollectLiveChannels(ctx, &options)
+	if err != nil {
+		return
+	}
+	aggregator.Add(time.Now(), channels)
+
+	return
+}
+
+// Summary summarises the snapshots taken from the start time up to
+// and including the end time, a zero time leaves that end unbounded.
+func (a *LiveAggregator) Summary(from time.Time, to time.Time) *LiveSummary {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	summary := &LiveSummary{
+		Games:  []*GameSummary{},
+		Series: []*LiveSnapshotPoint{},
+	}
+
+	var (
+		channels      = map[string]bool{}
+		games         = map[string]*GameSummary{}
+		gameChannels  = map[string]map[string]bool{}
+		totalChannels int
+		totalViewers  int
+	)
+	for _, snapshot := range a.snapshots {
+		if (!from.IsZero() && snapshot.at.Before(from)) || (!to.IsZero() && snapshot.at.After(to)) {
+			continue
+		}
+
+		if summary.Snapshots == 0 {
+			summary.From = snapshot.at
+		}
+		summary.To = snapshot.at
+		summary.Snapshots++
+
+		point := &LiveSnapshotPoint{At: snapshot.at, Channels: len(snapshot.channels)}
+		gameCounts := map[string]*GameSummary{}
+		for _, channel := range snapshot.channels {
+			point.Viewers += channel.viewers
+			channels[channel.id] = true
+
+			key := channel.gameID
+			if key == "" {
+				key = channel.game
+			}
+
+			game, ok := games[key]
+			if !ok {
+				game = &GameSummary{Game: channel.game, GameID: channel.gameID}
+				games[key] = game
+				gameChannels[key] = map[string]bool{}
+			}
+			gameChannels[key][channel.id] = true
+
+			count, ok := gameCounts[key]
+			if !ok {
+				count = &GameSummary{}
+				gameCounts[key] = count
+			}
+			count.PeakChannels++
+			count.PeakViewers += channel.viewers
+		}
+
+		for key, count := range gameCounts {
+			game := games[key]
+			if count.PeakChannels > game.PeakChannels {
+				game.PeakChannels = count.PeakChannels
+			}
+			if count.PeakViewers > game.PeakViewers {
+				game.PeakViewers = count.PeakViewers
+			}
+		}
+
+		if summary.Snapshots == 1 || point.Channels > summary.PeakChannels {
+			summary.PeakChannels = point.Channels
+			summary.PeakChannelsAt = point.At
+		}
+		if summary.Snapshots == 1 || point.Viewers > summary.PeakViewers {
+			summary.PeakViewers = point.Viewers
+			summary.PeakViewersAt = point.At
+		}
+		totalChannels += point.Channels
+		totalViewers += point.Viewers
+
+		summary.Series = append(summary.Series, point)
+	}
+
+	if summary.Snapshots == 0 {
+		return summary
+	}
+
+	summary.UniqueChannels = len(channels)
+	summary.AverageChannels = float64(totalChannels) / float64(summary.Snapshots)
+	summary.AverageViewers = float64(totalViewers) / float64(summary.Snapshots)
+
+	for key, game := range games {
+		game.UniqueChannels = len(gameChannels[key])
+		summary.Games = append(summary.Games, game)
+	}
+	sort.Slice(summary.Games, func(i, j int) bool {
+		a, b := summary.Games[i], summary.Games[j]
+		if a.UniqueChannels != b.UniqueChannels {
+			return a.UniqueChannels > b.UniqueChannels
+		}
+		if a.PeakViewers != b.PeakViewers {
+			return a.PeakViewers > b.PeakViewers
+		}
+		return a.Game < b.Game
+	})
+
+	return summary
+}
+
+// WriteJSON writes the summary to w as indented JSON
+func (s *LiveSummary) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(s)
+}
+
+// WriteCSV writes the time series of the summary to w as CSV,
+// with a header row followed by one row per snapshot.
+func (s *LiveSummary) WriteCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+
+	rows := [][]string{{"at", "channels", "viewers"}}
+	for _, point := range s.Series {
+		rows = append(rows, []string{
+			point.At.UTC().Format(time.RFC3339),
+			strconv.Itoa(point.Channels),
+			strconv.Itoa(point.Viewers),
+		})
+	}
+
+	return writer.WriteAll(rows)
+}
+
+// WriteGamesCSV writes the per-game breakdown of the summary to w as CSV,
+// with a header row followed by one row per game.
+func (s *LiveSummary) WriteGamesCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+
+	rows := [][]string{{"game", "game_id", "unique_channels", "peak_channels", "peak_viewers"}}
+	for _, game := range s.Games {
+		rows = append(rows, []string{
+			game.Game,
+			game.GameID,
+			strconv.Itoa(game.UniqueChannels),
+			strconv.Itoa(game.PeakChannels),
+			strconv.Itoa(game.PeakViewers),
+		})
+	}
+
+	return writer.WriteAll(rows)
+}
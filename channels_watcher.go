@@ -0,0 +1,259 @@
+package twitchext
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+const defaultChannelWatcherInterval = time.Minute
+
+// ChannelEventType the type of change to a live channel
+type ChannelEventType string
+
+// Types of live channel events
+const (
+	ChannelLive    ChannelEventType = "live"
+	ChannelOffline ChannelEventType = "offline"
+	ChannelUpdated ChannelEventType = "updated"
+)
+
+// ChannelChange a field of a live channel which changed
+type ChannelChange string
+
+// Types of live channel changes
+const (
+	ChannelTitleChanged   ChannelChange = "title"
+	ChannelGameChanged    ChannelChange = "game"
+	ChannelViewersChanged ChannelChange = "viewers"
+)
+
+// ChannelEvent a change to a live channel between two polls.
+// Previous is the channel as of the prior poll, it is nil for
+// ChannelLive events, and Channel is nil for ChannelOffline events.
+type ChannelEvent struct {
+	Type      ChannelEventType
+	ChannelID string
+	Channel   *Channel
+	Previous  *Channel
+	Changes   []ChannelChange
+	At        time.Time
+}
+
+// ChannelWatcherOptions optional parameters for the ChannelWatcher
+type ChannelWatcherOptions struct {
+	// Interval the time between polls, defaults to 1 minute
+	Interval time.Duration
+	// LiveChannels the options used to request every page of live
+	// channels, MaxResults is ignored. Helix viewers, and so
+	// ChannelViewersChanged events, require IncludeViewers.
+	LiveChannels *LiveChannelsOptions
+	// SkipInitial seeds the watcher with the channels live at the first
+	// poll without emitting ChannelLive events for them.
+	SkipInitial bool
+	// OnEvent is called with each event
+	OnEvent func(event *ChannelEvent)
+	// Events receives each event, sends block
+	// so the channel must be consumed.
+	Events chan<- *ChannelEvent
+	// OnError is called when a poll fails, the failed
+	// poll is skipped rather than reporting channels offline.
+	OnError func(err error)
+	// OnSnapshot is called with the channels live at each
+	// successful poll, such as LiveAggregator.Add.
+	OnSnapshot func(at time.Time, channels []*Channel)
+}
+
+// ChannelWatcher polls the live channels with the extension activated,
+// emitting events as channels go live, go offline or are updated.
+type ChannelWatcher struct {
+	twitch      *Twitch
+	interval    time.Duration
+	options     LiveChannelsOptions
+	skipInitial bool
+	onEvent     func(event *ChannelEvent)
+	events      chan<- *ChannelEvent
+	onError     func(err error)
+	onSnapshot  func(at time.Time, channels []*Channel)
+
+	mu       sync.Mutex
+	channels map[string]*Channel
+	seeded   bool
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewChannelWatcher create a watcher, polling
+// immediately and then on every interval.
+func (t *Twitch) NewChannelWatcher(opts ...*ChannelWatcherOptions) *ChannelWatcher {
+	w := &ChannelWatcher{
+		twitch:   t,
+		interval: defaultChannelWatcherInterval,
+		channels: map[string]*Channel{},
+	}
+
+	if len(opts) > 0 && opts[0] != nil {
+		if opts[0].Interval > 0 {
+			w.interval = opts[0].Interval
+		}
+		if opts[0].LiveChannels != nil {
+			w.options = *opts[0].LiveChannels
+		}
+		w.skipInitial = opts[0].SkipInitial
+		w.onEvent = opts[0].OnEvent
+		w.events = opts[0].Events
+		w.onError = opts[0].OnError
+		w.onSnapshot = opts[0].OnSnapshot
+	}
+	// every page is required to tell which channels went offline
+	w.options.MaxResults = 0
+
+	ctx, cancel := context.WithCancel(context.Background())
+	w.cancel = cancel
+
+	w.wg.Add(1)
+	go w.run(ctx)
+
+	return w
+}
+
+// Channels returns the live channels as of the last successful poll
+func (w *ChannelWatcher) Channels() []*Channel {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	channels := make([]*Channel, 0, len(w.channels))
+	for _, channel := range w.channels {
+		copied := *channel
+		channels = append(channels, &copied)
+	}
+	sort.Slice(channels, func(i, j int) bool {
+		return channels[i].ID < channels[j].ID
+	})
+
+	return channels
+}
+
+// Close stops polling, waiting for a poll in progress to finish
+func (w *ChannelWatcher) Close() {
+	w.cancel()
+	w.wg.Wait()
+}
+
+func (w *ChannelWatcher) run(ctx context.Context) {
+	defer w.wg.Done()
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		w.poll(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (w *ChannelWatcher) poll(ctx context.Context) {
+	channels, err := w.twitch.CollectLiveChannels(ctx, &w.options)
+	if err != nil {
+		if ctx.Err() == nil && w.onError != nil {
+			w.onError(err)
+		}
+		return
+	}
+	at := time.Now()
+
+	if w.onSnapshot != nil {
+		w.onSnapshot(at, channels)
+	}
+
+	current := make(map[string]*Channel, len(channels))
+	for _, channel := range channels {
+		current[channel.ID] = channel
+	}
+
+	w.mu.Lock()
+	previous := w.channels
+	w.channels = current
+	seeded := w.seeded
+	w.seeded = true
+	w.mu.Unlock()
+
+	if !seeded && w.skipInitial {
+		return
+	}
+
+	for _, event := range diffChannels(previous, current, at) {
+		if w.onEvent != nil {
+			w.onEvent(event)
+		}
+		if w.events != nil {
+			select {
+			case w.events <- event:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+// diffChannels the events between two snapshots of live channels,
+// ordered by channel ID.
+func diffChannels(previous map[string]*Channel, current map[string]*Channel, at time.Time) (events []*ChannelEvent) {
+	for id, channel := range current {
+		before, ok := previous[id]
+		if !ok {
+			events = append(events, &ChannelEvent{
+				Type:      ChannelLive,
+				ChannelID: id,
+				Channel:   channel,
+				At:        at,
+			})
+			continue
+		}
+
+		var changes []ChannelChange
+		if before.Title != channel.Title {
+			changes = append(changes, ChannelTitleChanged)
+		}
+		if before.Game != channel.Game || before.GameID != channel.GameID {
+			changes = append(changes, ChannelGameChanged)
+		}
+		if before.Viewers != channel.Viewers {
+			changes = append(changes, ChannelViewersChanged)
+		}
+		if len(changes) > 0 {
+			events = append(events, &ChannelEvent{
+				Type:      ChannelUpdated,
+				ChannelID: id,
+				Channel:   channel,
+				Previous:  before,
+				Changes:   changes,
+				At:        at,
+			})
+		}
+	}
+
+	for id, channel := range previous {
+		if _, ok := current[id]; !ok {
+			events = append(events, &ChannelEvent{
+				Type:      ChannelOffline,
+				ChannelID: id,
+				Previous:  channel,
+				At:        at,
+			})
+		}
+	}
+
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].ChannelID < events[j].ChannelID
+	})
+
+	return
+}
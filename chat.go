@@ -3,6 +3,8 @@ package twitchext
 import (
 	"fmt"
 	"net/http"
+	"net/url"
+	"unicode/utf8"
 
 	"github.com/jackmcguire1/go-twitch-ext/internal/utils"
 )
@@ -15,11 +17,24 @@ type chatMessage struct {
 	Text string `json:"text"`
 }
 
+// helixChatMessage the body of the Helix
+// Send Extension Chat Message endpoint.
+// https://dev.twitch.tv/docs/api/reference/#send-extension-chat-message
+type helixChatMessage struct {
+	Text             string `json:"text"`
+	ExtensionID      string `json:"extension_id"`
+	ExtensionVersion string `json:"extension_version"`
+}
+
 // SendTwitchChatMessage publish message to twitch chat of a specific channel ID.
 // - Twitch extension must have this permission
-// - The maximum message size is 280 character
+// - The maximum message size is 280 characters, counted as Unicode code points
 // - There is a limit of 12 messages per minute, per channel.
-// https://dev.twitch.tv/docs/extensions/reference/#send-extension-chat-message
+// Messages are first passed through the client Options.ChatFilters,
+// a blocked message is returned as a ChatBlockedError.
+// Messages are sent to the Helix endpoint unless the
+// client was created with Options.ChatAPI set to LegacyAPI.
+// https://dev.twitch.tv/docs/api/reference/#send-extension-chat-message
 func (t *Twitch) SendTwitchChatMessage(channelID string, message string) (res *ResponseCommon, err error) {
 
 	if channelID == "" {
@@ -27,7 +42,14 @@ func (t *Twitch) SendTwitchChatMessage(channelID string, message string) (res *R
 		return
 	}
 
-	if len(message) > maxMessageSize {
+	if len(t.chatFilters) > 0 {
+		message, err = FilterChatMessage(message, t.chatFilters...)
+		if err != nil {
+			return
+		}
+	}
+
+	if utf8.RuneCountInString(message) > maxMessageSize {
 		err = fmt.Errorf(
 			"message %q exceeds %d character limit",
 			message,
@@ -36,17 +58,37 @@ func (t *Twitch) SendTwitchChatMessage(channelID string, message string) (res *R
 		return
 	}
 
-	url := fmt.Sprintf(
-		"https://api.twitch.tv/extensions/%s/%s/channels/%s/chat",
-		t.ClientID,
-		t.Version,
-		channelID,
+	var (
+		addr   string
+		claims *TwitchJWTClaims
+		body   []byte
+		q      url.Values
 	)
-	claims := t.CreateClaims(channelID, BroadcasterRole, FormBroadcastSendPubSubPermissions())
 
-	msg := &chatMessage{Text: message}
+	switch t.chatAPI {
+	case LegacyAPI:
+		addr = fmt.Sprintf(
+			"https://api.twitch.tv/extensions/%s/%s/channels/%s/chat",
+			t.ClientID,
+			t.Version,
+			channelID,
+		)
+		claims = t.CreateClaims(channelID, BroadcasterRole, FormBroadcastSendPubSubPermissions())
+		body = utils.ToRawMessage(&chatMessage{Text: message})
+	default:
+		addr = "https://api.twitch.tv/helix/extensions/chat"
+		// Helix requires an external role JWT signed on behalf of the extension owner
+		claims = t.CreateClaims(channelID, ExternalRole, nil)
+		body = utils.ToRawMessage(&helixChatMessage{
+			Text:             message,
+			ExtensionID:      t.ClientID,
+			ExtensionVersion: t.Version,
+		})
+		q = url.Values{}
+		q.Set("broadcaster_id", channelID)
+	}
 
-	_, headers, err := t.do(http.MethodPost, url, claims, utils.ToRawMessage(msg), nil)
+	_, headers, err := t.do(http.MethodPost, addr, claims, body, q)
 	if err != nil {
 		return
 	}
@@ -0,0 +1,380 @@
+package twitchext
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	defaultChatWindow           = time.Minute
+	defaultChatRateLimitRetries = 3
+	chatMergeSeparator          = " | "
+)
+
+// ChatPriority the priority of a queued chat message,
+// higher priority messages are sent first.
+type ChatPriority int
+
+// Types of chat message priorities
+const (
+	ChatPriorityLow    ChatPriority = -1
+	ChatPriorityNormal ChatPriority = 0
+	ChatPriorityHigh   ChatPriority = 1
+)
+
+// ChatStatus the delivery outcome of a queued chat message
+type ChatStatus string
+
+// Types of chat delivery outcomes
+const (
+	ChatSent    ChatStatus = "sent"
+	ChatFailed  ChatStatus = "failed"
+	ChatDropped ChatStatus = "dropped"
+	ChatMerged  ChatStatus = "merged"
+)
+
+// ChatResult the delivery outcome of a queued chat message.
+// MergedInto is the ID of the message a merged message was sent within.
+type ChatResult struct {
+	ID         string
+	ChannelID  string
+	Text       string
+	Status     ChatStatus
+	MergedInto string
+	Err        error
+	Response   *ResponseCommon
+}
+
+// ChatDispatcherOptions optional parameters for the ChatDispatcher
+type ChatDispatcherOptions struct {
+	// MessagesPerWindow the number of messages sent per channel
+	// within each window, defaults to 12.
+	MessagesPerWindow int
+	// Window the rolling rate limit window, defaults to 1 minute.
+	Window time.Duration
+	// MaxAge the age after which a queued message is stale,
+	// stale messages are dropped unless MergeStale is set.
+	// Zero disables staleness.
+	MaxAge time.Duration
+	// MergeStale combines consecutive stale messages of the same
+	// priority into a single message while they fit the chat limit.
+	MergeStale bool
+	// OnResult is called with the outcome of each message
+	OnResult func(result *ChatResult)
+	// Results receives the outcome of each message, sends
+	// block so the channel must be consumed.
+	Results chan<- *ChatResult
+}
+
+// ChatDispatcher queues chat messages per channel and sends
+// them with SendTwitchChatMessage within the chat rate limit.
+type ChatDispatcher struct {
+	twitch     *Twitch
+	limit      int
+	window     time.Duration
+	maxAge     time.Duration
+	mergeStale bool
+	onResult   func(result *ChatResult)
+	results    chan<- *ChatResult
+
+	mu     sync.Mutex
+	queues map[string]*chatQueue
+	seq    uint64
+	closed bool
+	wg     sync.WaitGroup
+}
+
+type chatQueue struct {
+	entries []*queuedChat
+	sent    []time.Time
+	running bool
+	// blockedUntil the end of the window following a
+	// rate limit reached outside of the dispatcher.
+	blockedUntil time.Time
+}
+
+type queuedChat struct {
+	id       string
+	text     string
+	priority ChatPriority
+	queued   time.Time
+	seq      uint64
+	attempts int
+}
+
+// NewChatDispatcher create a rate limited chat dispatcher
+func (t *Twitch) NewChatDispatcher(opts ...*ChatDispatcherOptions) *ChatDispatcher {
+	d := &ChatDispatcher{
+		twitch: t,
+		limit:  maxChatMessagesPerMinute,
+		window: defaultChatWindow,
+		queues: map[string]*chatQueue{},
+	}
+
+	if len(opts) > 0 && opts[0] != nil {
+		if opts[0].MessagesPerWindow > 0 {
+			d.limit = opts[0].MessagesPerWindow
+		}
+		if opts[0].Window > 0 {
+			d.window = opts[0].Window
+		}
+		d.maxAge = opts[0].MaxAge
+		d.mergeStale = opts[0].MergeStale
+		d.onResult = opts[0].OnResult
+		d.results = opts[0].Results
+	}
+
+	return d
+}
+
+// Send queues a chat message for the channel, returning the ID
+// its outcome is reported with.
+func (d *ChatDispatcher) Send(channelID string, text string, priority ChatPriority) (id string, err error) {
+	if channelID == "" {
+		err = fmt.Errorf("missing channelID")
+		return
+	}
+
+	if utf8.RuneCountInString(text) > maxMessageSize {
+		err = fmt.Errorf(
+			"message %q exceeds %d character limit",
+			text,
+			maxMessageSize,
+		)
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.closed {
+		err = fmt.Errorf("chat dispatcher closed")
+		return
+	}
+
+	queue, ok := d.queues[channelID]
+	if !ok {
+		queue = &chatQueue{}
+		d.queues[channelID] = queue
+	}
+
+	d.seq++
+	entry := &queuedChat{
+		id:       newMessageID(),
+		text:     text,
+		priority: priority,
+		queued:   time.Now(),
+		seq:      d.seq,
+	}
+	queue.insert(entry)
+
+	if !queue.running {
+		queue.running = true
+		d.wg.Add(1)
+		go d.drain(channelID, queue)
+	}
+
+	id = entry.id
+
+	return
+}
+
+// Pending returns the number of messages queued for the channel
+func (d *ChatDispatcher) Pending(channelID string) int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	queue, ok := d.queues[channelID]
+	if !ok {
+		return 0
+	}
+	return len(queue.entries)
+}
+
+// Close stops accepting messages and waits for
+// the queued messages to be delivered.
+func (d *ChatDispatcher) Close() {
+	d.mu.Lock()
+	d.closed = true
+	d.mu.Unlock()
+
+	d.wg.Wait()
+}
+
+// insert adds the entry ordered by priority, then by the order queued
+func (q *chatQueue) insert(entry *queuedChat) {
+	i := sort.Search(len(q.entries), func(i int) bool {
+		existing := q.entries[i]
+		if existing.priority != entry.priority {
+			return existing.priority < entry.priority
+		}
+		return existing.seq > entry.seq
+	})
+
+	q.entries = append(q.entries, nil)
+	copy(q.entries[i+1:], q.entries[i:])
+	q.entries[i] = entry
+}
+
+// nextSend returns the time the channel may next be sent to
+func (q *chatQueue) nextSend(limit int, window time.Duration) time.Time {
+	now := time.Now()
+	for len(q.sent) > 0 && now.Sub(q.sent[0]) >= window {
+		q.sent = q.sent[1:]
+	}
+
+	if now.Before(q.blockedUntil) {
+		return q.blockedUntil
+	}
+	if len(q.sent) < limit {
+		return now
+	}
+	return q.sent[len(q.sent)-limit].Add(window)
+}
+
+func (d *ChatDispatcher) drain(channelID string, queue *chatQueue) {
+	defer d.wg.Done()
+
+	for {
+		d.mu.Lock()
+		next := queue.nextSend(d.limit, d.window)
+		if len(queue.entries) == 0 {
+			queue.running = false
+			if len(queue.sent) == 0 && !time.Now().Before(queue.blockedUntil) {
+				delete(d.queues, channelID)
+			}
+			d.mu.Unlock()
+			return
+		}
+
+		wait := time.Until(next)
+		if wait > 0 {
+			d.mu.Unlock()
+			time.Sleep(wait)
+			continue
+		}
+
+		entry, text, merged, dropped := d.take(queue)
+		if entry != nil {
+			queue.sent = append(queue.sent, time.Now())
+		}
+		d.mu.Unlock()
+
+		for _, stale := range dropped {
+			d.report(&ChatResult{
+				ID:        stale.id,
+				ChannelID: channelID,
+				Text:      stale.text,
+				Status:    ChatDropped,
+			})
+		}
+
+		if entry == nil {
+			continue
+		}
+
+		res, err := d.twitch.SendTwitchChatMessage(channelID, text)
+
+		var rateLimited *RateLimitError
+		if errors.As(err, &rateLimited) && entry.attempts < defaultChatRateLimitRetries {
+			// the channel limit was reached outside of the dispatcher,
+			// requeue the messages and wait for the window to pass
+			d.mu.Lock()
+			entry.attempts++
+			queue.insert(entry)
+			for _, m := range merged {
+				queue.insert(m)
+			}
+			queue.blockedUntil = time.Now().Add(d.window)
+			d.mu.Unlock()
+			continue
+		}
+
+		status := ChatSent
+		if err != nil {
+			status = ChatFailed
+		}
+
+		d.report(&ChatResult{
+			ID:        entry.id,
+			ChannelID: channelID,
+			Text:      text,
+			Status:    status,
+			Err:       err,
+			Response:  res,
+		})
+		for _, m := range merged {
+			d.report(&ChatResult{
+				ID:         m.id,
+				ChannelID:  channelID,
+				Text:       m.text,
+				Status:     ChatMerged,
+				MergedInto: entry.id,
+				Err:        err,
+				Response:   res,
+			})
+		}
+	}
+}
+
+// take removes the next message to send from the queue, returning the text
+// to send along with the stale messages merged into it or dropped.
+// The returned entry is nil when only a stale message was dropped.
+func (d *ChatDispatcher) take(queue *chatQueue) (entry *queuedChat, text string, merged []*queuedChat, dropped []*queuedChat) {
+	isStale := func(e *queuedChat) bool {
+		return d.maxAge > 0 && time.Since(e.queued) > d.maxAge
+	}
+
+	head := queue.entries[0]
+	queue.entries = queue.entries[1:]
+
+	if !isStale(head) {
+		entry = head
+		text = head.text
+		return
+	}
+
+	if !d.mergeStale {
+		dropped = append(dropped, head)
+		return
+	}
+
+	// merge following stale messages of the same priority while they fit
+	texts := []string{head.text}
+	length := utf8.RuneCountInString(head.text)
+	for len(queue.entries) > 0 {
+		next := queue.entries[0]
+		if next.priority != head.priority || !isStale(next) {
+			break
+		}
+
+		nextLength := length + utf8.RuneCountInString(chatMergeSeparator+next.text)
+		if nextLength > maxMessageSize {
+			break
+		}
+
+		texts = append(texts, next.text)
+		length = nextLength
+		merged = append(merged, next)
+		queue.entries = queue.entries[1:]
+	}
+
+	entry = head
+	text = strings.Join(texts, chatMergeSeparator)
+
+	return
+}
+
+func (d *ChatDispatcher) report(result *ChatResult) {
+	if d.onResult != nil {
+		d.onResult(result)
+	}
+	if d.results != nil {
+		d.results <- result
+	}
+}
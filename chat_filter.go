@@ -0,0 +1,152 @@
+package twitchext
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// ChatBlockReason the reason a chat message was blocked
+type ChatBlockReason string
+
+// Types of chat block reasons
+const (
+	// ChatBlockedDenyList the message contained a denied term
+	ChatBlockedDenyList ChatBlockReason = "deny_list"
+	// ChatBlockedEmpty the message was empty once filtered
+	ChatBlockedEmpty ChatBlockReason = "empty"
+	// ChatBlockedModeration a custom moderation filter rejected the message
+	ChatBlockedModeration ChatBlockReason = "moderation"
+)
+
+// ChatBlockedError is returned when a chat filter blocks a message.
+// Err is the error returned by a custom moderation filter.
+type ChatBlockedError struct {
+	Reason  ChatBlockReason
+	Detail  string
+	Message string
+	Err     error
+}
+
+func (e *ChatBlockedError) Error() string {
+	return fmt.Sprintf("chat message %q blocked reason:%s detail:%s", e.Message, e.Reason, e.Detail)
+}
+
+// Unwrap returns the error of the custom moderation filter
+func (e *ChatBlockedError) Unwrap() error {
+	return e.Err
+}
+
+// ChatFilter inspects a chat message before it is sent, returning the
+// message to send, which may be rewritten, or an error to block it.
+// Errors other than a ChatBlockedError are reported as moderation blocks.
+type ChatFilter func(message string) (string, error)
+
+// FilterChatMessage runs the message through the filters in order,
+// blocking messages left empty by the filters.
+func FilterChatMessage(message string, filters ...ChatFilter) (filtered string, err error) {
+	filtered = message
+	for _, filter := range filters {
+		filtered, err = filter(filtered)
+		if err != nil {
+			var blocked *ChatBlockedError
+			if !errors.As(err, &blocked) {
+				err = &ChatBlockedError{
+					Reason:  ChatBlockedModeration,
+					Detail:  err.Error(),
+					Message: message,
+					Err:     err,
+				}
+			}
+			filtered = ""
+			return
+		}
+	}
+
+	if strings.TrimSpace(filtered) == "" {
+		err = &ChatBlockedError{
+			Reason:  ChatBlockedEmpty,
+			Detail:  "message empty after filtering",
+			Message: message,
+		}
+		filtered = ""
+		return
+	}
+
+	return
+}
+
+// DenyListFilter blocks messages containing any of the terms, matched
+// case-insensitively against whole words. Terms of several words
+// match those words in sequence, ignoring punctuation between them.
+func DenyListFilter(terms ...string) ChatFilter {
+	normalised := make([]string, 0, len(terms))
+	for _, term := range terms {
+		if words := chatWords(term); len(words) > 0 {
+			normalised = append(normalised, " "+strings.Join(words, " ")+" ")
+		}
+	}
+
+	return func(message string) (string, error) {
+		words := " " + strings.Join(chatWords(message), " ") + " "
+		for _, term := range normalised {
+			if strings.Contains(words, term) {
+				return "", &ChatBlockedError{
+					Reason:  ChatBlockedDenyList,
+					Detail:  fmt.Sprintf("denied term %q", strings.TrimSpace(term)),
+					Message: message,
+				}
+			}
+		}
+		return message, nil
+	}
+}
+
+// chatWords the lower cased words of a message
+func chatWords(message string) []string {
+	return strings.FieldsFunc(strings.ToLower(message), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+var chatURLPattern = regexp.MustCompile(
+	`(?i)\b(?:[a-z][a-z0-9+.-]*://|www\.)\S+` +
+		`|\b(?:[a-z0-9-]+\.)+(?:com|net|org|tv|gg|io|ly|me|co|app|dev|xyz|uk)\b(?:/\S*)?`,
+)
+
+// StripURLsFilter replaces links and bare domains within the message
+// with the replacement, which may be empty to remove them.
+func StripURLsFilter(replacement string) ChatFilter {
+	return func(message string) (string, error) {
+		return chatURLPattern.ReplaceAllLiteralString(message, replacement), nil
+	}
+}
+
+// StripControlCharactersFilter removes control and invisible formatting
+// characters, such as zero width spaces and bidirectional overrides,
+// replacing line breaks and tabs with spaces.
+func StripControlCharactersFilter() ChatFilter {
+	return func(message string) (string, error) {
+		return strings.Map(func(r rune) rune {
+			switch {
+			case r == '\n' || r == '\r' || r == '\t':
+				return ' '
+			case unicode.IsControl(r) || unicode.Is(unicode.Cf, r):
+				return -1
+			}
+			return r
+		}, message), nil
+	}
+}
+
+var chatMentionPattern = regexp.MustCompile(`(^|[^\w@])@+(\w)`)
+
+// NeutraliseMentionsFilter removes the @ from mentions of users,
+// so viewer supplied text cannot ping chatters.
+func NeutraliseMentionsFilter() ChatFilter {
+	return func(message string) (string, error) {
+		return chatMentionPattern.ReplaceAllString(message, "${1}${2}"), nil
+	}
+}
@@ -0,0 +1,151 @@
+package twitchext
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// maxChatMessagesPerMinute the number of chat messages
+	// an extension may send per minute, per channel.
+	maxChatMessagesPerMinute = 12
+
+	defaultChatContinuation = "…"
+)
+
+// ChatSplitOptions optional parameters used when
+// splitting long chat messages into multiple sends
+type ChatSplitOptions struct {
+	// Continuation the marker appended to every part but the last and
+	// prepended to every part but the first, defaults to "…".
+	Continuation string
+	// MaxParts the maximum number of parts a message may be split into,
+	// defaults to 12, the per channel chat message limit per minute.
+	MaxParts int
+}
+
+// SplitChatMessage splits text exceeding the 280 character chat limit into
+// parts at word boundaries, marking where each part continues. Words longer
+// than a part are split mid-word and runs of whitespace between words are
+// collapsed into a single space. Text within the limit is returned unchanged.
+func SplitChatMessage(text string, opts ...*ChatSplitOptions) (parts []string, err error) {
+	if utf8.RuneCountInString(text) <= maxMessageSize {
+		parts = []string{text}
+		return
+	}
+
+	options := &ChatSplitOptions{}
+	if len(opts) > 0 && opts[0] != nil {
+		options = opts[0]
+	}
+
+	continuation := options.Continuation
+	if continuation == "" {
+		continuation = defaultChatContinuation
+	}
+
+	maxParts := options.MaxParts
+	if maxParts <= 0 {
+		maxParts = maxChatMessagesPerMinute
+	}
+
+	// reserve room for a marker at both ends of a part
+	budget := maxMessageSize - 2*utf8.RuneCountInString(continuation)
+	if budget <= 0 {
+		err = fmt.Errorf("continuation marker %q exceeds %d character limit", continuation, maxMessageSize)
+		return
+	}
+
+	var (
+		chunks  []string
+		current []rune
+	)
+	for _, word := range strings.Fields(text) {
+		runes := []rune(word)
+
+		if len(current) > 0 && len(current)+1+len(runes) <= budget {
+			current = append(append(current, ' '), runes...)
+			continue
+		}
+
+		if len(current) > 0 {
+			chunks = append(chunks, string(current))
+			current = nil
+		}
+
+		for len(runes) > budget {
+			chunks = append(chunks, string(runes[:budget]))
+			runes = runes[budget:]
+		}
+		current = runes
+	}
+	if len(current) > 0 {
+		chunks = append(chunks, string(current))
+	}
+
+	if len(chunks) == 0 {
+		err = fmt.Errorf("message exceeds %d character limit but contains only whitespace", maxMessageSize)
+		return
+	}
+
+	if len(chunks) > maxParts {
+		err = fmt.Errorf("message requires %d parts exceeding the %d part limit", len(chunks), maxParts)
+		return
+	}
+
+	for i, chunk := range chunks {
+		if i > 0 {
+			chunk = continuation + chunk
+		}
+		if i < len(chunks)-1 {
+			chunk += continuation
+		}
+		parts = append(parts, chunk)
+	}
+
+	return
+}
+
+// SendTwitchChatMessages sends text to the chat of a channel, splitting it
+// with SplitChatMessage when it exceeds the 280 character limit. Each part is
+// a separate chat message counted against the 12 messages per minute limit,
+// sending stops once the chat rate limit reports no remaining messages.
+// The responses of the parts sent are returned.
+func (t *Twitch) SendTwitchChatMessages(
+	channelID string,
+	text string,
+	opts ...*ChatSplitOptions,
+) (
+	responses []*ResponseCommon,
+	err error,
+) {
+	parts, err := SplitChatMessage(text, opts...)
+	if err != nil {
+		return
+	}
+
+	for i, part := range parts {
+		var res *ResponseCommon
+		res, err = t.SendTwitchChatMessage(channelID, part)
+		if err != nil {
+			err = fmt.Errorf("failed to send chat message part:%d/%d err:%w", i+1, len(parts), err)
+			return
+		}
+		responses = append(responses, res)
+
+		if i < len(parts)-1 &&
+			res.Headers.Get("Ratelimit-Ratelimiterextensionchatmessages-Remaining") != "" &&
+			res.GetExtSendChatMessageRateLimitRemaining() == 0 {
+			err = fmt.Errorf(
+				"chat rate limit exhausted after part:%d/%d err:%w",
+				i+1,
+				len(parts),
+				&RateLimitError{Headers: res.Headers},
+			)
+			return
+		}
+	}
+
+	return
+}
@@ -0,0 +1,256 @@
+package twitchext
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"text/template"
+	"unicode/utf8"
+)
+
+// Plural categories returned by a PluralRule
+const (
+	PluralZero  = "zero"
+	PluralOne   = "one"
+	PluralTwo   = "two"
+	PluralFew   = "few"
+	PluralMany  = "many"
+	PluralOther = "other"
+)
+
+// PluralRule selects the plural category of a count for a locale
+type PluralRule func(n float64) string
+
+// DefaultPluralRule the plural rule used by locales without their own rule,
+// selecting "one" for a count of exactly 1 and "other" otherwise.
+func DefaultPluralRule(n float64) string {
+	if n == 1 {
+		return PluralOne
+	}
+	return PluralOther
+}
+
+// ChatCatalog per-locale chat message templates, written using
+// text/template, rendered and length checked before being sent.
+//
+// Templates may use the plural function, which selects text by the
+// plural category of a count within the locale being rendered,
+// falling back to the "other" text:
+//
+//	{{.Count}} {{plural .Count "one" "winner" "other" "winners"}}
+type ChatCatalog struct {
+	fallback string
+
+	mu        sync.RWMutex
+	templates map[string]map[string]*template.Template
+	rules     map[string]PluralRule
+}
+
+// NewChatCatalog create a catalog, messages missing from
+// a locale are rendered using the fallback locale.
+func NewChatCatalog(fallbackLocale string) *ChatCatalog {
+	return &ChatCatalog{
+		fallback:  normaliseLocale(fallbackLocale),
+		templates: map[string]map[string]*template.Template{},
+		rules:     map[string]PluralRule{},
+	}
+}
+
+func normaliseLocale(locale string) string {
+	return strings.ToLower(strings.ReplaceAll(locale, "_", "-"))
+}
+
+// SetPluralRule sets the plural rule of a locale
+func (c *ChatCatalog) SetPluralRule(locale string, rule PluralRule) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.rules[normaliseLocale(locale)] = rule
+}
+
+// Add parses and stores the template of a message key for a locale
+func (c *ChatCatalog) Add(locale string, key string, text string) (err error) {
+	locale = normaliseLocale(locale)
+
+	tmpl, err := template.New(key).
+		Option("missingkey=error").
+		Funcs(template.FuncMap{"plural": c.pluralFunc(locale)}).
+		Parse(text)
+	if err != nil {
+		err = fmt.Errorf("invalid chat template key:%s locale:%s err:%s", key, locale, err)
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.templates[locale]; !ok {
+		c.templates[locale] = map[string]*template.Template{}
+	}
+	c.templates[locale][key] = tmpl
+
+	return
+}
+
+// AddMessages stores the templates of many message keys for a locale
+func (c *ChatCatalog) AddMessages(locale string, messages map[string]string) (err error) {
+	for key, text := range messages {
+		err = c.Add(locale, key, text)
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// Render executes the template of the message key for the locale. A region
+// specific locale such as "pt-BR" falls back to "pt" and then to the catalog
+// fallback locale. An error is returned when the rendered message exceeds
+// the 280 character chat limit.
+func (c *ChatCatalog) Render(locale string, key string, data interface{}) (message string, err error) {
+	tmpl, err := c.lookup(normaliseLocale(locale), key)
+	if err != nil {
+		return
+	}
+
+	builder := &strings.Builder{}
+	err = tmpl.Execute(builder, data)
+	if err != nil {
+		err = fmt.Errorf("failed to render chat template key:%s err:%s", key, err)
+		return
+	}
+	message = builder.String()
+
+	if utf8.RuneCountInString(message) > maxMessageSize {
+		err = fmt.Errorf(
+			"message %q exceeds %d character limit",
+			message,
+			maxMessageSize,
+		)
+		return
+	}
+
+	return
+}
+
+func (c *ChatCatalog) lookup(locale string, key string) (*template.Template, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	candidates := []string{locale}
+	if i := strings.Index(locale, "-"); i > 0 {
+		candidates = append(candidates, locale[:i])
+	}
+	candidates = append(candidates, c.fallback)
+
+	for _, candidate := range candidates {
+		if tmpl, ok := c.templates[candidate][key]; ok {
+			return tmpl, nil
+		}
+	}
+
+	return nil, fmt.Errorf("missing chat template key:%s locale:%s", key, locale)
+}
+
+func (c *ChatCatalog) pluralRule(locale string) PluralRule {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if rule, ok := c.rules[locale]; ok {
+		return rule
+	}
+	if i := strings.Index(locale, "-"); i > 0 {
+		if rule, ok := c.rules[locale[:i]]; ok {
+			return rule
+		}
+	}
+	return DefaultPluralRule
+}
+
+// pluralFunc the plural template function of a locale, taking a count followed
+// by category and text pairs, falling back to the "other" category text.
+func (c *ChatCatalog) pluralFunc(locale string) func(count interface{}, forms ...string) (string, error) {
+	return func(count interface{}, forms ...string) (string, error) {
+		if len(forms)%2 != 0 {
+			return "", fmt.Errorf("plural requires category and text pairs")
+		}
+
+		n, err := toFloat(count)
+		if err != nil {
+			return "", err
+		}
+
+		category := c.pluralRule(locale)(n)
+
+		other, hasOther := "", false
+		for i := 0; i < len(forms); i += 2 {
+			if forms[i] == category {
+				return forms[i+1], nil
+			}
+			if forms[i] == PluralOther {
+				other, hasOther = forms[i+1], true
+			}
+		}
+
+		if !hasOther {
+			return "", fmt.Errorf("plural missing category:%s", category)
+		}
+		return other, nil
+	}
+}
+
+func toFloat(v interface{}) (float64, error) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), nil
+	case int8:
+		return float64(n), nil
+	case int16:
+		return float64(n), nil
+	case int32:
+		return float64(n), nil
+	case int64:
+		return float64(n), nil
+	case uint:
+		return float64(n), nil
+	case uint8:
+		return float64(n), nil
+	case uint16:
+		return float64(n), nil
+	case uint32:
+		return float64(n), nil
+	case uint64:
+		return float64(n), nil
+	case float32:
+		return float64(n), nil
+	case float64:
+		if math.IsNaN(n) {
+			return 0, fmt.Errorf("plural count is NaN")
+		}
+		return n, nil
+	}
+
+	return 0, fmt.Errorf("plural count %v is not a number", v)
+}
+
+// SendTemplatedChatMessage renders a catalog message for
+// the locale and sends it to the chat of the channel.
+func (t *Twitch) SendTemplatedChatMessage(
+	channelID string,
+	catalog *ChatCatalog,
+	locale string,
+	key string,
+	data interface{},
+) (
+	res *ResponseCommon,
+	err error,
+) {
+	message, err := catalog.Render(locale, key, data)
+	if err != nil {
+		return
+	}
+
+	return t.SendTwitchChatMessage(channelID, message)
+}
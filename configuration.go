@@ -28,12 +28,39 @@ type configurationParams struct {
 }
 
 // AllConfigurationsResponse contains all possible
-// segment configurations for a broadcaster
+// segment configurations for a broadcaster, a segment
+// field is nil when it has not been set.
 type AllConfigurationsResponse struct {
+	Broadcaster *Configuration `json:"broadcaster,omitempty"`
+	Developer   *Configuration `json:"developer,omitempty"`
+	Global      *Configuration `json:"global,omitempty"`
+	// Configurations contains every returned segment keyed by segment type
 	Configurations map[string]*Configuration `json:"configurations"`
+	// Raw contains every returned segment keyed by
+	// the original "segment:channelID" key.
+	Raw map[string]*Configuration `json:"raw"`
 	ResponseCommon
 }
 
+// Segment returns the configuration of the given segment type
+func (r *AllConfigurationsResponse) Segment(segment SegmentType) *Configuration {
+	switch segment {
+	case BroadcasterSegment:
+		return r.Broadcaster
+	case DeveloperSegment:
+		return r.Developer
+	case GlobalSegment:
+		return r.Global
+	}
+
+	return nil
+}
+
+// Decode unmarshals the JSON content of the given segment into v
+func (r *AllConfigurationsResponse) Decode(segment SegmentType, v interface{}) error {
+	return r.Segment(segment).Decode(v)
+}
+
 // ConfigurationResponse contains data for the queried
 // segment configuration  of type global, broadcaster or developer
 type ConfigurationResponse struct {
@@ -47,6 +74,15 @@ type Configuration struct {
 	Record  *Record  `json:"record"`
 }
 
+// Decode unmarshals the JSON record content into v
+func (c *Configuration) Decode(v interface{}) error {
+	if c == nil || c.Record == nil {
+		return fmt.Errorf("configuration record missing")
+	}
+
+	return c.Record.Decode(v)
+}
+
 // Record contains information about the
 // version and raw content stored within
 // the SegmentType configuation
@@ -55,6 +91,30 @@ type Record struct {
 	Content string `json:"content"`
 }
 
+// Decode unmarshals the JSON record content into v
+func (r *Record) Decode(v interface{}) error {
+	if r == nil {
+		return fmt.Errorf("configuration record missing")
+	}
+
+	return json.Unmarshal([]byte(r.Content), v)
+}
+
+// ConfigurationMissingError is returned when the queried
+// segment has never been set for the channel.
+type ConfigurationMissingError struct {
+	Segment   SegmentType
+	ChannelID string
+}
+
+func (e *ConfigurationMissingError) Error() string {
+	return fmt.Sprintf(
+		"Configuration missing segment:%s channelID:%s",
+		e.Segment,
+		e.ChannelID,
+	)
+}
+
 // Segment contains information about the
 // type and twitch channel the configuration
 // is for.
@@ -63,19 +123,29 @@ type Segment struct {
 	ChannelID string `json:"channel_id,omitempty"`
 }
 
+// SegmentOptions optional parameters used
+// when setting a configuration segment
+type SegmentOptions struct {
+	// NotifyChange publishes a ConfigurationChangedNotification
+	// containing a JSON patch of the changes made to the segment.
+	// Global segment changes are published to all channels, broadcaster
+	// and developer segment changes to the channel itself.
+	NotifyChange bool
+}
+
 // SetGlobalSegment sets global extension configuration
-func (t *Twitch) SetGlobalSegment(data interface{}) (res *ResponseCommon, err error) {
-	return t.setSegmentConfig(data, "", GlobalSegment)
+func (t *Twitch) SetGlobalSegment(data interface{}, opts ...*SegmentOptions) (res *ResponseCommon, err error) {
+	return t.setSegment(data, "", GlobalSegment, opts...)
 }
 
 // SetBroadcasterSegment sets channel specific broadcaster SegmentType configuration
-func (t *Twitch) SetBroadcasterSegment(data interface{}, channelID string) (res *ResponseCommon, err error) {
-	return t.setSegmentConfig(data, channelID, BroadcasterSegment)
+func (t *Twitch) SetBroadcasterSegment(data interface{}, channelID string, opts ...*SegmentOptions) (res *ResponseCommon, err error) {
+	return t.setSegment(data, channelID, BroadcasterSegment, opts...)
 }
 
 // SetDeveloperSegment sets channel specific developer SegmentType configuration
-func (t *Twitch) SetDeveloperSegment(data interface{}, channelID string) (res *ResponseCommon, err error) {
-	return t.setSegmentConfig(data, channelID, DeveloperSegment)
+func (t *Twitch) SetDeveloperSegment(data interface{}, channelID string, opts ...*SegmentOptions) (res *ResponseCommon, err error) {
+	return t.setSegment(data, channelID, DeveloperSegment, opts...)
 }
 
 // GetGlobalSegment retrieves global extension SegmentType configuration
@@ -94,11 +164,12 @@ func (t *Twitch) GetDeveloperSegment(channelID string) (*ConfigurationResponse,
 }
 
 // GetAllChannelConfigurations retrieves channel specific configuration
-// returns map containing both broadcaster and developer segments.
+// returning both the broadcaster and developer segments.
 // https://dev.twitch.tv/docs/extensions/reference/#get-extension-channel-configuration
 func (t *Twitch) GetAllChannelConfigurations(channelID string) (resp *AllConfigurationsResponse, err error) {
 	resp = &AllConfigurationsResponse{
 		Configurations: map[string]*Configuration{},
+		Raw:            map[string]*Configuration{},
 	}
 
 	addr := fmt.Sprintf(
@@ -119,23 +190,38 @@ func (t *Twitch) GetAllChannelConfigurations(channelID string) (resp *AllConfigu
 		return
 	}
 
-	for segmentName, segment := range configurations {
-		resp.Configurations[strings.Split(segmentName, ":")[0]] = segment
+	for key, config := range configurations {
+		segment := strings.Split(key, ":")[0]
+		resp.Configurations[segment] = config
+		resp.Raw[key] = config
+
+		switch SegmentType(segment) {
+		case BroadcasterSegment:
+			resp.Broadcaster = config
+		case DeveloperSegment:
+			resp.Developer = config
+		case GlobalSegment:
+			resp.Global = config
+		}
 	}
 	resp.Headers = headers
 
 	return
 }
 
-// https://dev.twitch.tv/docs/extensions/reference/#set-extension-configuration-segment
 func (t *Twitch) setSegmentConfig(data interface{}, channelID string, segment SegmentType) (res *ResponseCommon, err error) {
+	return t.setSegmentRecord(utils.ToJSON(data), t.ConfigVersion, channelID, segment)
+}
+
+// https://dev.twitch.tv/docs/extensions/reference/#set-extension-configuration-segment
+func (t *Twitch) setSegmentRecord(content string, version string, channelID string, segment SegmentType) (res *ResponseCommon, err error) {
 	addr := fmt.Sprintf("https://api.twitch.tv/extensions/%s/configurations/", t.ClientID)
 
 	claims := t.CreateClaims(channelID, ExternalRole, FormBroadcastSendPubSubPermissions())
 	segmentConfig := configurationParams{
 		Segment: segment,
-		Content: utils.ToJSON(data),
-		Version: t.ConfigVersion,
+		Content: content,
+		Version: version,
 	}
 
 	if segment != GlobalSegment {
@@ -178,11 +264,10 @@ func (t *Twitch) getSegmentConfig(channelID string, segment SegmentType) (resp *
 
 	configuration, ok := config[fmt.Sprintf("%s:%s", string(segment), channelID)]
 	if !ok {
-		err = fmt.Errorf(
-			"Configuration missing segment:%s channelID:%s",
-			segment,
-			channelID,
-		)
+		err = &ConfigurationMissingError{
+			Segment:   segment,
+			ChannelID: channelID,
+		}
 		return
 	}
 
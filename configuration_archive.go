@@ -0,0 +1,280 @@
+package twitchext
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+const (
+	defaultImportRateLimitWait = 5 * time.Second
+	defaultImportMaxRetries    = 3
+	maxArchiveLineSize         = 1024 * 1024
+)
+
+// ConfigurationArchiveEntry a single segment record stored
+// within a JSON-lines configuration archive.
+type ConfigurationArchiveEntry struct {
+	Segment   SegmentType `json:"segment"`
+	ChannelID string      `json:"channel_id,omitempty"`
+	Version   string      `json:"version"`
+	Content   string      `json:"content"`
+}
+
+// ExportOptions optional parameters for ExportConfigurations
+type ExportOptions struct {
+	// Channels the channel IDs to export broadcaster
+	// and developer segments for.
+	Channels []string
+	// AllLiveChannels additionally exports every live channel
+	// with the extension activated.
+	AllLiveChannels bool
+	// ExtensionID the extension used to look up live channels,
+	// defaults to the client ID.
+	ExtensionID string
+}
+
+// ExportReport summary of an exported configuration archive
+type ExportReport struct {
+	Channels int
+	Entries  int
+}
+
+// ImportOptions optional parameters for ImportConfigurations
+type ImportOptions struct {
+	// DryRun validates and counts the archive entries
+	// without writing any configuration.
+	DryRun bool
+	// RateLimitWait the time to wait once the set configuration
+	// rate limit is exhausted, defaults to 5 seconds.
+	RateLimitWait time.Duration
+	// MaxRetries the number of times an entry is retried
+	// after being rate limited, defaults to 3.
+	MaxRetries int
+}
+
+// ImportReport summary of an imported configuration archive
+type ImportReport struct {
+	Entries     int
+	Written     int
+	RateLimited int
+	DryRun      bool
+}
+
+// ExportConfigurations writes the global segment and the broadcaster and
+// developer segments of each requested channel to w as JSON-lines,
+// one ConfigurationArchiveEntry per line. Segments that have not been set
+// are skipped.
+func (t *Twitch) ExportConfigurations(w io.Writer, opts ...*ExportOptions) (report *ExportReport, err error) {
+	options := &ExportOptions{}
+	if len(opts) > 0 && opts[0] != nil {
+		options = opts[0]
+	}
+
+	channels, err := t.exportChannels(options)
+	if err != nil {
+		return
+	}
+
+	report = &ExportReport{Channels: len(channels)}
+	encoder := json.NewEncoder(w)
+
+	global, err := t.getSegmentRecord("", GlobalSegment)
+	if err != nil {
+		return
+	}
+	if global != nil {
+		err = encoder.Encode(&ConfigurationArchiveEntry{
+			Segment: GlobalSegment,
+			Version: global.Version,
+			Content: global.Content,
+		})
+		if err != nil {
+			return
+		}
+		report.Entries++
+	}
+
+	for _, channelID := range channels {
+		var resp *AllConfigurationsResponse
+		resp, err = t.GetAllChannelConfigurations(channelID)
+		if err != nil {
+			err = fmt.Errorf("failed to export channel:%s err:%w", channelID, err)
+			return
+		}
+
+		for _, segment := range []SegmentType{BroadcasterSegment, DeveloperSegment} {
+			config := resp.Segment(segment)
+			if config == nil || config.Record == nil {
+				continue
+			}
+
+			err = encoder.Encode(&ConfigurationArchiveEntry{
+				Segment:   segment,
+				ChannelID: channelID,
+				Version:   config.Record.Version,
+				Content:   config.Record.Content,
+			})
+			if err != nil {
+				return
+			}
+			report.Entries++
+		}
+	}
+
+	return
+}
+
+func (t *Twitch) exportChannels(options *ExportOptions) (channels []string, err error) {
+	seen := map[string]bool{}
+	for _, channelID := range options.Channels {
+		if !seen[channelID] {
+			seen[channelID] = true
+			channels = append(channels, channelID)
+		}
+	}
+
+	if !options.AllLiveChannels {
+		return
+	}
+
+	extensionID := options.ExtensionID
+	if extensionID == "" {
+		extensionID = t.ClientID
+	}
+
+	var bookmark string
+	for {
+		var resp *ExtensionEnabledChannels
+		resp, err = t.GetLiveChannelsWithExtensionEnabled(extensionID, bookmark)
+		if err != nil {
+			return
+		}
+
+		for _, channel := range resp.Channels {
+			if !seen[channel.ID] {
+				seen[channel.ID] = true
+				channels = append(channels, channel.ID)
+			}
+		}
+
+		if resp.Bookmark == "" || resp.Bookmark == bookmark {
+			return
+		}
+		bookmark = resp.Bookmark
+	}
+}
+
+// ImportConfigurations replays a configuration archive produced by
+// ExportConfigurations into the extension of this client, preserving
+// the archived record versions. Writes pause once the set configuration
+// rate limit is exhausted and rate limited writes are retried.
+func (t *Twitch) ImportConfigurations(r io.Reader, opts ...*ImportOptions) (report *ImportReport, err error) {
+	options := &ImportOptions{}
+	if len(opts) > 0 && opts[0] != nil {
+		options = opts[0]
+	}
+
+	wait := options.RateLimitWait
+	if wait <= 0 {
+		wait = defaultImportRateLimitWait
+	}
+
+	retries := options.MaxRetries
+	if retries <= 0 {
+		retries = defaultImportMaxRetries
+	}
+
+	report = &ImportReport{DryRun: options.DryRun}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxArchiveLineSize)
+
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		entry := &ConfigurationArchiveEntry{}
+		err = json.Unmarshal(scanner.Bytes(), entry)
+		if err != nil {
+			err = fmt.Errorf("invalid archive entry line:%d err:%s", line, err)
+			return
+		}
+
+		err = validateArchiveEntry(entry)
+		if err != nil {
+			err = fmt.Errorf("invalid archive entry line:%d err:%s", line, err)
+			return
+		}
+		report.Entries++
+
+		if options.DryRun {
+			continue
+		}
+
+		err = t.importArchiveEntry(entry, wait, retries, report)
+		if err != nil {
+			err = fmt.Errorf("failed to import archive entry line:%d err:%w", line, err)
+			return
+		}
+		report.Written++
+	}
+
+	err = scanner.Err()
+
+	return
+}
+
+func (t *Twitch) importArchiveEntry(
+	entry *ConfigurationArchiveEntry,
+	wait time.Duration,
+	retries int,
+	report *ImportReport,
+) (
+	err error,
+) {
+	for attempt := 0; ; attempt++ {
+		var res *ResponseCommon
+		res, err = t.setSegmentRecord(entry.Content, entry.Version, entry.ChannelID, entry.Segment)
+
+		var rateLimited *RateLimitError
+		if errors.As(err, &rateLimited) && attempt < retries {
+			report.RateLimited++
+			time.Sleep(wait)
+			continue
+		}
+		if err != nil {
+			return
+		}
+
+		if res.Headers.Get("Ratelimit-Ratelimiterextensionsetconfiguration-Remaining") != "" &&
+			res.GetExtSetConfigurationRateLimitRemaining() == 0 {
+			time.Sleep(wait)
+		}
+
+		return
+	}
+}
+
+func validateArchiveEntry(entry *ConfigurationArchiveEntry) error {
+	switch entry.Segment {
+	case GlobalSegment:
+		if entry.ChannelID != "" {
+			return fmt.Errorf("global segment must not have a channel ID")
+		}
+	case BroadcasterSegment, DeveloperSegment:
+		if entry.ChannelID == "" {
+			return fmt.Errorf("segment:%s missing channel ID", entry.Segment)
+		}
+	default:
+		return fmt.Errorf("unsupported segment:%q", entry.Segment)
+	}
+
+	return nil
+}
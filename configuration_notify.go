@@ -0,0 +1,190 @@
+package twitchext
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/jackmcguire1/go-twitch-ext/internal/utils"
+)
+
+// ConfigurationChangedEvent the notification type published
+// when a configuration segment is changed.
+const ConfigurationChangedEvent = "config_changed"
+
+// Types of JSON patch operations produced by DiffJSON
+const (
+	PatchAdd     = "add"
+	PatchRemove  = "remove"
+	PatchReplace = "replace"
+)
+
+// PatchOperation a single RFC 6902 JSON patch operation
+type PatchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// ConfigurationChangedNotification the PubSub message published
+// when a segment is set with SegmentOptions.NotifyChange enabled.
+type ConfigurationChangedNotification struct {
+	Type      string            `json:"type"`
+	Segment   SegmentType       `json:"segment"`
+	ChannelID string            `json:"channel_id,omitempty"`
+	Version   string            `json:"version"`
+	Patch     []*PatchOperation `json:"patch"`
+}
+
+// DiffJSON computes a structural diff between two JSON documents
+// and returns it as a list of JSON patch operations.
+// Objects are compared key by key, arrays and scalar values
+// are replaced as a whole when they differ. An empty document
+// is treated as null.
+func DiffJSON(oldContent string, newContent string) (patch []*PatchOperation, err error) {
+	var oldValue, newValue interface{}
+
+	if oldContent != "" {
+		err = json.Unmarshal([]byte(oldContent), &oldValue)
+		if err != nil {
+			err = fmt.Errorf("failed to decode old content err:%s", err)
+			return
+		}
+	}
+
+	if newContent != "" {
+		err = json.Unmarshal([]byte(newContent), &newValue)
+		if err != nil {
+			err = fmt.Errorf("failed to decode new content err:%s", err)
+			return
+		}
+	}
+
+	patch = []*PatchOperation{}
+	diffValues("", oldValue, newValue, &patch)
+
+	return
+}
+
+func diffValues(path string, oldValue interface{}, newValue interface{}, patch *[]*PatchOperation) {
+	oldObject, oldIsObject := oldValue.(map[string]interface{})
+	newObject, newIsObject := newValue.(map[string]interface{})
+
+	if !oldIsObject || !newIsObject {
+		if !reflect.DeepEqual(oldValue, newValue) {
+			*patch = append(*patch, &PatchOperation{
+				Op:    PatchReplace,
+				Path:  path,
+				Value: utils.ToRawMessage(newValue),
+			})
+		}
+		return
+	}
+
+	keys := make([]string, 0, len(oldObject)+len(newObject))
+	for key := range oldObject {
+		keys = append(keys, key)
+	}
+	for key := range newObject {
+		if _, ok := oldObject[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		keyPath := path + "/" + escapeJSONPointer(key)
+		oldChild, inOld := oldObject[key]
+		newChild, inNew := newObject[key]
+
+		switch {
+		case inOld && !inNew:
+			*patch = append(*patch, &PatchOperation{Op: PatchRemove, Path: keyPath})
+		case !inOld && inNew:
+			*patch = append(*patch, &PatchOperation{
+				Op:    PatchAdd,
+				Path:  keyPath,
+				Value: utils.ToRawMessage(newChild),
+			})
+		default:
+			diffValues(keyPath, oldChild, newChild, patch)
+		}
+	}
+}
+
+func escapeJSONPointer(key string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(key)
+}
+
+// setSegment sets the segment configuration, publishing a change
+// notification when requested by the segment options.
+func (t *Twitch) setSegment(
+	data interface{},
+	channelID string,
+	segment SegmentType,
+	opts ...*SegmentOptions,
+) (
+	res *ResponseCommon,
+	err error,
+) {
+	if len(opts) == 0 || opts[0] == nil || !opts[0].NotifyChange {
+		return t.setSegmentConfig(data, channelID, segment)
+	}
+
+	previous, err := t.getSegmentRecord(channelID, segment)
+	if err != nil {
+		return
+	}
+
+	res, err = t.setSegmentConfig(data, channelID, segment)
+	if err != nil {
+		return
+	}
+
+	var oldContent string
+	if previous != nil {
+		oldContent = previous.Content
+	}
+
+	patch, err := DiffJSON(oldContent, utils.ToJSON(data))
+	if err != nil {
+		return
+	}
+	if len(patch) == 0 {
+		return
+	}
+
+	err = t.publishConfigurationChanged(segment, channelID, patch)
+	if err != nil {
+		err = fmt.Errorf("configuration set but change notification failed err:%w", err)
+	}
+
+	return
+}
+
+func (t *Twitch) publishConfigurationChanged(
+	segment SegmentType,
+	channelID string,
+	patch []*PatchOperation,
+) (
+	err error,
+) {
+	notification := &ConfigurationChangedNotification{
+		Type:    ConfigurationChangedEvent,
+		Segment: segment,
+		Version: t.ConfigVersion,
+		Patch:   patch,
+	}
+
+	if segment == GlobalSegment {
+		_, err = t.PublishGlobalNotification(notification)
+		return
+	}
+
+	notification.ChannelID = channelID
+	_, err = t.PublishChannelNotification(channelID, notification)
+
+	return
+}
@@ -0,0 +1,129 @@
+package twitchext
+
+import (
+	"fmt"
+)
+
+// OnboardingStatus the configuration state of a channel
+type OnboardingStatus string
+
+// Types of channel onboarding states
+const (
+	// OnboardingUnconfigured the channel has not set the segment
+	OnboardingUnconfigured OnboardingStatus = "unconfigured"
+	// OnboardingOutdated the segment version does not match the client ConfigVersion
+	OnboardingOutdated OnboardingStatus = "outdated"
+	// OnboardingInvalid the segment is current but was rejected by the validator
+	OnboardingInvalid OnboardingStatus = "invalid"
+	// OnboardingConfigured the segment is current and valid
+	OnboardingConfigured OnboardingStatus = "configured"
+)
+
+// ConfigurationValidator validates the content of a segment record,
+// returning an error describing why the configuration is unusable.
+type ConfigurationValidator func(record *Record) error
+
+// OnboardingOptions optional parameters used
+// when evaluating a channels onboarding status
+type OnboardingOptions struct {
+	// Segment the segment holding the channels setup,
+	// defaults to the broadcaster segment.
+	Segment SegmentType
+	// Validator an optional content validator
+	Validator ConfigurationValidator
+}
+
+// OnboardingResult the evaluated onboarding state of a channel
+type OnboardingResult struct {
+	Status          OnboardingStatus
+	Record          *Record
+	ValidationError error
+}
+
+// OnboardingError is returned by CompleteOnboarding when
+// the channel has not finished configuring the extension.
+type OnboardingError struct {
+	ChannelID string
+	Result    *OnboardingResult
+}
+
+func (e *OnboardingError) Error() string {
+	if e.Result.ValidationError != nil {
+		return fmt.Sprintf(
+			"channel:%s onboarding status:%s err:%s",
+			e.ChannelID,
+			e.Result.Status,
+			e.Result.ValidationError,
+		)
+	}
+
+	return fmt.Sprintf("channel:%s onboarding status:%s", e.ChannelID, e.Result.Status)
+}
+
+func (e *OnboardingError) Unwrap() error {
+	return e.Result.ValidationError
+}
+
+// GetOnboardingStatus evaluates whether a channel has configured the
+// extension for the clients ConfigVersion, validating the segment
+// content when a validator is supplied.
+func (t *Twitch) GetOnboardingStatus(channelID string, opts ...*OnboardingOptions) (result *OnboardingResult, err error) {
+	if channelID == "" {
+		err = fmt.Errorf("missing channelID")
+		return
+	}
+
+	options := &OnboardingOptions{}
+	if len(opts) > 0 && opts[0] != nil {
+		options = opts[0]
+	}
+
+	segment := options.Segment
+	if segment == "" {
+		segment = BroadcasterSegment
+	}
+
+	record, err := t.getSegmentRecord(channelID, segment)
+	if err != nil {
+		return
+	}
+
+	result = &OnboardingResult{Record: record}
+
+	switch {
+	case record == nil:
+		result.Status = OnboardingUnconfigured
+	case record.Version != t.ConfigVersion:
+		result.Status = OnboardingOutdated
+	default:
+		result.Status = OnboardingConfigured
+		if options.Validator != nil {
+			result.ValidationError = options.Validator(record)
+			if result.ValidationError != nil {
+				result.Status = OnboardingInvalid
+			}
+		}
+	}
+
+	return
+}
+
+// CompleteOnboarding marks the channels required configuration as
+// complete via SetExtensionRequired, but only once the channel
+// segment is current and passes validation. An OnboardingError is
+// returned describing the channels status otherwise.
+func (t *Twitch) CompleteOnboarding(channelID string, opts ...*OnboardingOptions) (result *OnboardingResult, res *ResponseCommon, err error) {
+	result, err = t.GetOnboardingStatus(channelID, opts...)
+	if err != nil {
+		return
+	}
+
+	if result.Status != OnboardingConfigured {
+		err = &OnboardingError{ChannelID: channelID, Result: result}
+		return
+	}
+
+	res, err = t.SetExtensionRequired(channelID)
+
+	return
+}
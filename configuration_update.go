@@ -0,0 +1,131 @@
+package twitchext
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/jackmcguire1/go-twitch-ext/internal/utils"
+)
+
+const defaultUpdateSegmentAttempts = 3
+
+// ErrConfigurationConflict is returned by UpdateSegment when
+// the segment kept changing underneath the update and the
+// maximum number of attempts was exhausted.
+var ErrConfigurationConflict = errors.New("configuration segment modified concurrently")
+
+// SegmentMutator receives the current segment record, or nil
+// when the segment has not been set yet, and returns the new
+// content to be stored.
+type SegmentMutator func(current *Record) (interface{}, error)
+
+// UpdateSegmentOptions optional parameters for UpdateSegment
+type UpdateSegmentOptions struct {
+	// MaxAttempts is the number of read-modify-write cycles
+	// attempted before giving up, defaults to 3.
+	MaxAttempts int
+}
+
+// RecordHash returns a hex encoded sha256 hash of the record
+// version and content, an empty string is returned for a nil record.
+func RecordHash(record *Record) string {
+	if record == nil {
+		return ""
+	}
+
+	sum := sha256.Sum256([]byte(record.Version + "\x00" + record.Content))
+	return hex.EncodeToString(sum[:])
+}
+
+// UpdateSegment performs a compare-and-set style update of a configuration segment.
+// The current record is read and handed to mutate, the segment is re-read
+// before writing to detect concurrent modifications and read again after
+// writing to confirm our content was not clobbered. On conflict the
+// whole cycle is retried, up to MaxAttempts times.
+func (t *Twitch) UpdateSegment(
+	segment SegmentType,
+	channelID string,
+	mutate SegmentMutator,
+	opts ...*UpdateSegmentOptions,
+) (
+	res *ResponseCommon,
+	err error,
+) {
+	attempts := defaultUpdateSegmentAttempts
+	if len(opts) > 0 && opts[0] != nil && opts[0].MaxAttempts > 0 {
+		attempts = opts[0].MaxAttempts
+	}
+
+	for i := 0; i < attempts; i++ {
+		var current *Record
+		current, err = t.getSegmentRecord(channelID, segment)
+		if err != nil {
+			return
+		}
+
+		var mutation *Record
+		if current != nil {
+			mutation = &Record{Version: current.Version, Content: current.Content}
+		}
+
+		var data interface{}
+		data, err = mutate(mutation)
+		if err != nil {
+			return
+		}
+
+		var latest *Record
+		latest, err = t.getSegmentRecord(channelID, segment)
+		if err != nil {
+			return
+		}
+		if RecordHash(latest) != RecordHash(current) {
+			continue
+		}
+
+		res, err = t.setSegmentConfig(data, channelID, segment)
+		if err != nil {
+			return
+		}
+
+		latest, err = t.getSegmentRecord(channelID, segment)
+		if err != nil {
+			return
+		}
+		if latest != nil && latest.Content == utils.ToJSON(data) {
+			return
+		}
+	}
+
+	err = fmt.Errorf(
+		"%w segment:%s channelID:%s attempts:%d",
+		ErrConfigurationConflict,
+		segment,
+		channelID,
+		attempts,
+	)
+	res = nil
+
+	return
+}
+
+// getSegmentRecord retrieves the record of a segment,
+// returning a nil record if the segment has not been set.
+func (t *Twitch) getSegmentRecord(channelID string, segment SegmentType) (record *Record, err error) {
+	resp, err := t.getSegmentConfig(channelID, segment)
+	if err != nil {
+		var missing *ConfigurationMissingError
+		if errors.As(err, &missing) {
+			err = nil
+		}
+		return
+	}
+
+	if resp.Configuration != nil {
+		record = resp.Configuration.Record
+	}
+
+	return
+}
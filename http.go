@@ -16,6 +16,36 @@ type ResponseCommon struct {
 	Headers http.Header
 }
 
+// RateLimitError is returned when Twitch rejects
+// a request with a 429 Too Many Requests status code.
+type RateLimitError struct {
+	Headers http.Header
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf(
+		"rate limit exceeded, response headers:%q",
+		utils.ToJSON(e.Headers),
+	)
+}
+
+// APIError is returned when Twitch responds
+// with an unexpected HTTP status code.
+type APIError struct {
+	StatusCode int
+	Status     string
+	Body       string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf(
+		"unsupported response httpCode:%d status:%s body:%q",
+		e.StatusCode,
+		e.Status,
+		e.Body,
+	)
+}
+
 func (rc *ResponseCommon) convertHeaderToInt(header string) (v int) {
 	v, _ = strconv.Atoi(rc.Headers.Get(header))
 	return
@@ -62,16 +92,37 @@ func (t *Twitch) do(
 	data []byte,
 	headers http.Header,
 	err error,
+) {
+	var token string
+	if claims != nil {
+		token, err = t.JWTSign(claims)
+		if err != nil {
+			return
+		}
+	}
+
+	return t.doWithToken(method, url, token, b, q)
+}
+
+// doWithToken sends a request authorised by a bearer token,
+// such as a signed JWT or an app access token.
+func (t *Twitch) doWithToken(
+	method string,
+	url string,
+	token string,
+	b []byte,
+	q url.Values,
+) (
+	data []byte,
+	headers http.Header,
+	err error,
 ) {
 	req, err := http.NewRequest(method, url, bytes.NewReader(b))
 	if err != nil {
 		err = fmt.Errorf("failed to construct request err:%s", err)
 		return
 	}
-	err = t.setExtensionRequestHeaders(req, claims)
-	if err != nil {
-		return
-	}
+	t.setExtensionRequestHeaders(req, token)
 	req.URL.RawQuery = q.Encode()
 
 	resp, err := t.client.Do(req)
@@ -87,41 +138,25 @@ func (t *Twitch) do(
 		data, err = ioutil.ReadAll(resp.Body)
 	case http.StatusNoContent:
 	case http.StatusTooManyRequests:
-		err = fmt.Errorf(
-			"rate limit exceeded, response headers:%q",
-			utils.ToJSON(headers),
-		)
+		err = &RateLimitError{Headers: headers}
 		return
 	default:
 		body, _ := ioutil.ReadAll(resp.Body)
-		err = fmt.Errorf(
-			"unsupported response httpCode:%d status:%s body:%q",
-			resp.StatusCode,
-			resp.Status,
-			string(body),
-		)
+		err = &APIError{
+			StatusCode: resp.StatusCode,
+			Status:     resp.Status,
+			Body:       string(body),
+		}
 		return
 	}
 
 	return
 }
 
-func (t *Twitch) setExtensionRequestHeaders(
-	req *http.Request,
-	claims *TwitchJWTClaims,
-) (
-	err error,
-) {
-	if claims != nil {
-		var token string
-		token, err = t.JWTSign(claims)
-		if err != nil {
-			return
-		}
+func (t *Twitch) setExtensionRequestHeaders(req *http.Request, token string) {
+	if token != "" {
 		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
 	}
 	req.Header.Set("Client-ID", t.ClientID)
 	req.Header.Set("Content-Type", "application/json")
-
-	return
 }
@@ -1,8 +1,10 @@
 package twitchext
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
+	"strings"
 
 	"github.com/jackmcguire1/go-twitch-ext/internal/utils"
 )
@@ -17,12 +19,38 @@ const (
 	GlobalPublish    PublishType = "global"
 )
 
+const defaultPubSubContentType = "application/json"
+
 type pubSubNotification struct {
 	Message     string        `json:"message"`
 	Targets     []PublishType `json:"targets"`
 	ContentType string        `json:"content_type"`
 }
 
+// helixPubSubNotification the body of the Helix
+// Send Extension PubSub Message endpoint.
+// https://dev.twitch.tv/docs/api/reference/#send-extension-pubsub-message
+type helixPubSubNotification struct {
+	Target            []PublishType `json:"target"`
+	BroadcasterID     string        `json:"broadcaster_id,omitempty"`
+	IsGlobalBroadcast bool          `json:"is_global_broadcast"`
+	Message           string        `json:"message"`
+}
+
+// UnsupportedContentTypeError is returned when a message with a content
+// type other than application/json is published to the Helix endpoint,
+// which has no content type field.
+type UnsupportedContentTypeError struct {
+	ContentType string
+}
+
+func (e *UnsupportedContentTypeError) Error() string {
+	return fmt.Sprintf(
+		"content type %q is not supported by the helix pubsub endpoint, use the legacy endpoint, a chunked message or an idempotency key",
+		e.ContentType,
+	)
+}
+
 // PubSubPermissions publish permissions used within
 // JWT claims
 type PubSubPermissions struct {
@@ -30,8 +58,74 @@ type PubSubPermissions struct {
 	Listen []PublishType `json:"listen,omitempty"`
 }
 
+const whisperPrefix = "whisper-"
+
 func createWhisper(opaqueId string) PublishType {
-	return PublishType("whisper-" + opaqueId)
+	return PublishType(whisperPrefix + opaqueId)
+}
+
+// WhisperTarget returns the whisper target type for an opaque user ID
+func WhisperTarget(opaqueId string) PublishType {
+	return createWhisper(opaqueId)
+}
+
+// NewPubSubPermissions create validated pubsub permissions
+// from explicit send and listen targets.
+func NewPubSubPermissions(send []PublishType, listen []PublishType) (permissions *PubSubPermissions, err error) {
+	permissions = &PubSubPermissions{
+		Send:   send,
+		Listen: listen,
+	}
+
+	err = permissions.Validate()
+	if err != nil {
+		permissions = nil
+	}
+
+	return
+}
+
+// Validate rejects empty permissions, unknown or duplicated targets,
+// whispers without an opaque user ID and the generic target
+// combined with other targets it already covers.
+func (p *PubSubPermissions) Validate() error {
+	if len(p.Send) == 0 && len(p.Listen) == 0 {
+		return fmt.Errorf("pubsub permissions have no send or listen targets")
+	}
+
+	err := validatePubSubTargets("send", p.Send)
+	if err != nil {
+		return err
+	}
+
+	return validatePubSubTargets("listen", p.Listen)
+}
+
+func validatePubSubTargets(permission string, targets []PublishType) error {
+	seen := map[PublishType]bool{}
+
+	for _, target := range targets {
+		switch {
+		case target == GenericPublish, target == BroadcastPublish, target == GlobalPublish:
+		case strings.HasPrefix(string(target), whisperPrefix):
+			if target == whisperPrefix {
+				return fmt.Errorf("%s whisper target missing opaque user ID", permission)
+			}
+		default:
+			return fmt.Errorf("unsupported %s target:%q", permission, target)
+		}
+
+		if seen[target] {
+			return fmt.Errorf("duplicate %s target:%q", permission, target)
+		}
+		seen[target] = true
+	}
+
+	if seen[GenericPublish] && len(targets) > 1 {
+		return fmt.Errorf("generic %s target cannot be combined with other targets", permission)
+	}
+
+	return nil
 }
 
 // FormWhisperSendPubSubPermissions create the pubsub permissions
@@ -58,6 +152,65 @@ func FormGlobalSendPubSubPermissions() *PubSubPermissions {
 	}
 }
 
+// FormWhisperListenPubSubPermissions create the pubsub permissions
+// for listening to whisper messages sent to a user
+func FormWhisperListenPubSubPermissions(opaqueId string) *PubSubPermissions {
+	return &PubSubPermissions{
+		Listen: []PublishType{createWhisper(opaqueId)},
+	}
+}
+
+// FormBroadcastListenPubSubPermissions create the pubsub permissions
+// for listening to broadcast messages
+func FormBroadcastListenPubSubPermissions() *PubSubPermissions {
+	return &PubSubPermissions{
+		Listen: []PublishType{BroadcastPublish},
+	}
+}
+
+// FormGlobalListenPubSubPermissions create the pubsub permissions
+// for listening to global targeted messages
+func FormGlobalListenPubSubPermissions() *PubSubPermissions {
+	return &PubSubPermissions{
+		Listen: []PublishType{GlobalPublish},
+	}
+}
+
+// FormWhisperSendListenPubSubPermissions create the pubsub permissions
+// for both publishing and listening to whisper messages of a user
+func FormWhisperSendListenPubSubPermissions(opaqueId string) *PubSubPermissions {
+	return &PubSubPermissions{
+		Send:   []PublishType{createWhisper(opaqueId)},
+		Listen: []PublishType{createWhisper(opaqueId)},
+	}
+}
+
+// FormBroadcastSendListenPubSubPermissions create the pubsub permissions
+// for both publishing and listening to broadcast messages
+func FormBroadcastSendListenPubSubPermissions() *PubSubPermissions {
+	return &PubSubPermissions{
+		Send:   []PublishType{BroadcastPublish},
+		Listen: []PublishType{BroadcastPublish},
+	}
+}
+
+// FormGlobalSendListenPubSubPermissions create the pubsub permissions
+// for both publishing and listening to global targeted messages
+func FormGlobalSendListenPubSubPermissions() *PubSubPermissions {
+	return &PubSubPermissions{
+		Send:   []PublishType{GlobalPublish},
+		Listen: []PublishType{GlobalPublish},
+	}
+}
+
+// FormViewerListenPubSubPermissions create the pubsub permissions
+// a viewer uses to listen to broadcast, global and their own whisper messages
+func FormViewerListenPubSubPermissions(opaqueId string) *PubSubPermissions {
+	return &PubSubPermissions{
+		Listen: []PublishType{BroadcastPublish, GlobalPublish, createWhisper(opaqueId)},
+	}
+}
+
 // FormGenericPubSubPermissions create the pubsub permissions
 // for publishing to message for any target type
 func FormGenericPubSubPermissions() *PubSubPermissions {
@@ -66,60 +219,219 @@ func FormGenericPubSubPermissions() *PubSubPermissions {
 	}
 }
 
-// PublishChannelNotification publish a notification to
-// a specific channel with the twitch extension enabled.
-func (t *Twitch) PublishChannelNotification(channelID string, i interface{}) (res *ResponseCommon, err error) {
-	url := fmt.Sprintf("https://api.twitch.tv/extensions/message/%s", channelID)
-	claims := t.CreateClaims(channelID, ExternalRole, FormBroadcastSendPubSubPermissions())
+// PubSubMessage a PubSub message and its targets, built
+// using NewPubSubMessage and sent with Publish.
+type PubSubMessage struct {
+	channelID   string
+	targets     []PublishType
+	contentType string
+	message     string
+	chunked     bool
+	idempotency string
+	err         error
+}
 
-	data := &pubSubNotification{
-		Message:     utils.ToJSON(i),
-		Targets:     []PublishType{BroadcastPublish},
-		ContentType: "application/json",
+// NewPubSubMessage creates a PubSub message for the given channel,
+// the channel ID may be empty for messages only targeting global.
+// Messages default to the application/json content type.
+func NewPubSubMessage(channelID string) *PubSubMessage {
+	return &PubSubMessage{
+		channelID:   channelID,
+		contentType: defaultPubSubContentType,
 	}
-	_, headers, err := t.do(http.MethodPost, url, claims, utils.ToRawMessage(data), nil)
+}
+
+// Broadcast targets every viewer of the channel
+func (m *PubSubMessage) Broadcast() *PubSubMessage {
+	return m.addTargets(BroadcastPublish)
+}
+
+// Global targets every channel with the extension enabled
+func (m *PubSubMessage) Global() *PubSubMessage {
+	return m.addTargets(GlobalPublish)
+}
+
+// Whisper targets each of the opaque user IDs
+func (m *PubSubMessage) Whisper(opaqueIDs ...string) *PubSubMessage {
+	for _, opaqueID := range opaqueIDs {
+		m.addTargets(createWhisper(opaqueID))
+	}
+	return m
+}
+
+// ContentType sets the content type of the message
+func (m *PubSubMessage) ContentType(contentType string) *PubSubMessage {
+	m.contentType = contentType
+	return m
+}
+
+// JSON sets the message to the JSON encoding of i
+func (m *PubSubMessage) JSON(i interface{}) *PubSubMessage {
+	data, err := json.Marshal(i)
 	if err != nil {
-		return
+		m.err = fmt.Errorf("failed to marshal pubsub message err:%s", err)
+		return m
 	}
-	res = &ResponseCommon{Headers: headers}
+	m.message = string(data)
+	return m
+}
 
-	return
+// Raw sets a pre-serialized message, sent as is
+func (m *PubSubMessage) Raw(message string) *PubSubMessage {
+	m.message = message
+	return m
 }
 
-// PublishWhisperNotification publish a notification to
-// a specific user viewing the twitch extension.
-func (t *Twitch) PublishWhisperNotification(channelID string, opaqueId string, i interface{}) (res *ResponseCommon, err error) {
-	url := fmt.Sprintf("https://api.twitch.tv/extensions/message/%s", channelID)
-	claims := t.CreateClaims(channelID, ExternalRole, FormWhisperSendPubSubPermissions(opaqueId))
+// Chunked allows messages exceeding the PubSub size limit to be
+// split into sequenced PubSubChunk fragments, published one after
+// another and reassembled by viewers using a PubSubReassembler.
+func (m *PubSubMessage) Chunked() *PubSubMessage {
+	m.chunked = true
+	return m
+}
 
-	data := &pubSubNotification{
-		Message:     utils.ToJSON(i),
-		Targets:     []PublishType{createWhisper(opaqueId)},
-		ContentType: "application/json",
+// Targets returns the targets of the message
+func (m *PubSubMessage) Targets() []PublishType {
+	return m.targets
+}
+
+// Permissions returns the send permissions required to publish the message
+func (m *PubSubMessage) Permissions() *PubSubPermissions {
+	return &PubSubPermissions{
+		Send: append([]PublishType{}, m.targets...),
 	}
-	_, headers, err := t.do(http.MethodPost, url, claims, utils.ToRawMessage(data), nil)
+}
+
+func (m *PubSubMessage) addTargets(targets ...PublishType) *PubSubMessage {
+	for _, target := range targets {
+		duplicate := false
+		for _, existing := range m.targets {
+			if existing == target {
+				duplicate = true
+				break
+			}
+		}
+		if !duplicate {
+			m.targets = append(m.targets, target)
+		}
+	}
+	return m
+}
+
+func (m *PubSubMessage) validate() error {
+	if m.err != nil {
+		return m.err
+	}
+
+	if len(m.targets) == 0 {
+		return fmt.Errorf("pubsub message has no targets")
+	}
+
+	for _, target := range m.targets {
+		if target == GlobalPublish {
+			if len(m.targets) > 1 {
+				return fmt.Errorf("global pubsub messages cannot have additional targets")
+			}
+			return nil
+		}
+	}
+
+	if m.channelID == "" {
+		return fmt.Errorf("missing channelID")
+	}
+
+	return nil
+}
+
+// Publish sends a PubSub message to all of its targets,
+// signing a token with matching send permissions.
+// Messages are sent to the Helix endpoint unless the client was
+// created with the LegacyAPI PubSub family. The Helix endpoint has no
+// content type, other content types are rejected with an
+// UnsupportedContentTypeError unless the message is Chunked or has an
+// IdempotencyKey, whose markers carry the content type to viewers.
+// Messages larger than 5KB are rejected with a MessageTooLargeError
+// unless the message is Chunked.
+// https://dev.twitch.tv/docs/api/reference/#send-extension-pubsub-message
+func (t *Twitch) Publish(msg *PubSubMessage) (res *ResponseCommon, err error) {
+	err = msg.validate()
 	if err != nil {
 		return
 	}
-	res = &ResponseCommon{Headers: headers}
 
-	return
+	if t.helixContentType(msg) && !msg.chunked && msg.idempotency == "" {
+		err = &UnsupportedContentTypeError{ContentType: msg.contentType}
+		return
+	}
+
+	if msg.idempotency != "" {
+		return t.publishIdempotent(msg)
+	}
+
+	return t.send(msg)
 }
 
-// PublishGlobalNotification publish a notification to
-// all channels with the twitch extension enabled.
-// https://dev.twitch.tv/docs/extensions/reference/#send-extension-pubsub-message
-func (t *Twitch) PublishGlobalNotification(i interface{}) (res *ResponseCommon, err error) {
-	url := "https://api.twitch.tv/extensions/message/all"
-	claims := t.CreateClaims("", ExternalRole, FormGlobalSendPubSubPermissions())
+// send publishes the message, chunking it when permitted
+func (t *Twitch) send(msg *PubSubMessage) (res *ResponseCommon, err error) {
+	// the chunk marker carries the content type the helix endpoint cannot
+	if t.helixContentType(msg) && msg.chunked {
+		return t.publishChunks(msg)
+	}
+
+	if len(msg.message) > maxPubSubMessageSize {
+		if !msg.chunked {
+			err = &MessageTooLargeError{Size: len(msg.message), Limit: maxPubSubMessageSize}
+			return
+		}
+		return t.publishChunks(msg)
+	}
+
+	return t.publish(msg)
+}
+
+// helixContentType reports whether the message has a content
+// type which cannot be sent to the helix endpoint as is
+func (t *Twitch) helixContentType(msg *PubSubMessage) bool {
+	return t.pubSubAPI != LegacyAPI && msg.contentType != defaultPubSubContentType
+}
+
+func (t *Twitch) publish(msg *PubSubMessage) (res *ResponseCommon, err error) {
+	channelID := msg.channelID
+	global := msg.targets[0] == GlobalPublish
+	if global {
+		channelID = ""
+	}
+	claims := t.CreateClaims(channelID, ExternalRole, msg.Permissions())
+
+	var (
+		addr string
+		body []byte
+	)
 
-	data := &pubSubNotification{
-		Message:     utils.ToJSON(i),
-		Targets:     []PublishType{GlobalPublish},
-		ContentType: "application/json",
+	switch t.pubSubAPI {
+	case LegacyAPI:
+		addr = fmt.Sprintf("https://api.twitch.tv/extensions/message/%s", toAllChannels)
+		if channelID != "" {
+			addr = fmt.Sprintf("https://api.twitch.tv/extensions/message/%s", channelID)
+		}
+
+		body = utils.ToRawMessage(&pubSubNotification{
+			Message:     msg.message,
+			Targets:     msg.targets,
+			ContentType: msg.contentType,
+		})
+	default:
+		addr = "https://api.twitch.tv/helix/extensions/pubsub"
+
+		body = utils.ToRawMessage(&helixPubSubNotification{
+			Message:           msg.message,
+			Target:            msg.targets,
+			BroadcasterID:     channelID,
+			IsGlobalBroadcast: global,
+		})
 	}
 
-	_, headers, err := t.do(http.MethodPost, url, claims, utils.ToRawMessage(data), nil)
+	_, headers, err := t.do(http.MethodPost, addr, claims, body, nil)
 	if err != nil {
 		return
 	}
@@ -127,3 +439,22 @@ func (t *Twitch) PublishGlobalNotification(i interface{}) (res *ResponseCommon,
 
 	return
 }
+
+// PublishChannelNotification publish a notification to
+// a specific channel with the twitch extension enabled.
+func (t *Twitch) PublishChannelNotification(channelID string, i interface{}) (res *ResponseCommon, err error) {
+	return t.Publish(NewPubSubMessage(channelID).Broadcast().JSON(i))
+}
+
+// PublishWhisperNotification publish a notification to
+// a specific user viewing the twitch extension.
+func (t *Twitch) PublishWhisperNotification(channelID string, opaqueId string, i interface{}) (res *ResponseCommon, err error) {
+	return t.Publish(NewPubSubMessage(channelID).Whisper(opaqueId).JSON(i))
+}
+
+// PublishGlobalNotification publish a notification to
+// all channels with the twitch extension enabled.
+// https://dev.twitch.tv/docs/api/reference/#send-extension-pubsub-message
+func (t *Twitch) PublishGlobalNotification(i interface{}) (res *ResponseCommon, err error) {
+	return t.Publish(NewPubSubMessage("").Global().JSON(i))
+}
@@ -0,0 +1,250 @@
+package twitchext
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jackmcguire1/go-twitch-ext/internal/utils"
+)
+
+const (
+	// maxPubSubMessageSize the maximum size in bytes of a PubSub message
+	maxPubSubMessageSize = 5 * 1024
+
+	// PubSubChunkType the type of PubSubChunk fragments
+	PubSubChunkType = "pubsub_chunk"
+
+	defaultReassemblerTTL = time.Minute
+)
+
+// MessageTooLargeError is returned when a PubSub message
+// exceeds the 5KB limit imposed by Twitch.
+type MessageTooLargeError struct {
+	Size  int
+	Limit int
+}
+
+func (e *MessageTooLargeError) Error() string {
+	return fmt.Sprintf("pubsub message size:%d exceeds %d byte limit", e.Size, e.Limit)
+}
+
+// PubSubChunk a fragment of a chunked PubSub message,
+// Data contains the base64 encoded bytes of the fragment.
+type PubSubChunk struct {
+	Type        string `json:"type"`
+	MessageID   string `json:"message_id"`
+	Sequence    int    `json:"seq"`
+	Total       int    `json:"total"`
+	ContentType string `json:"content_type"`
+	Data        string `json:"data"`
+}
+
+// newMessageID generates a random hex encoded message ID
+func newMessageID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// splitPubSubMessage splits a message into chunks which,
+// once JSON encoded, fit within the PubSub size limit.
+func splitPubSubMessage(messageID string, contentType string, message string) (chunks []*PubSubChunk, err error) {
+	data := []byte(message)
+
+	// the largest possible header, sequence numbers never exceed the data size
+	header := &PubSubChunk{
+		Type:        PubSubChunkType,
+		MessageID:   messageID,
+		Sequence:    len(data),
+		Total:       len(data),
+		ContentType: contentType,
+	}
+	budget := maxPubSubMessageSize - len(utils.ToJSON(header))
+	chunkSize := budget / 4 * 3
+	if chunkSize <= 0 {
+		err = fmt.Errorf("pubsub chunk header exceeds %d byte limit", maxPubSubMessageSize)
+		return
+	}
+
+	total := (len(data) + chunkSize - 1) / chunkSize
+	for seq := 0; seq < total; seq++ {
+		end := (seq + 1) * chunkSize
+		if end > len(data) {
+			end = len(data)
+		}
+
+		chunks = append(chunks, &PubSubChunk{
+			Type:        PubSubChunkType,
+			MessageID:   messageID,
+			Sequence:    seq,
+			Total:       total,
+			ContentType: contentType,
+			Data:        base64.StdEncoding.EncodeToString(data[seq*chunkSize : end]),
+		})
+	}
+
+	return
+}
+
+// publishChunks publishes each fragment of a chunked message in sequence
+func (t *Twitch) publishChunks(msg *PubSubMessage) (res *ResponseCommon, err error) {
+	chunks, err := splitPubSubMessage(newMessageID(), msg.contentType, msg.message)
+	if err != nil {
+		return
+	}
+
+	for _, chunk := range chunks {
+		fragment := &PubSubMessage{
+			channelID:   msg.channelID,
+			targets:     msg.targets,
+			contentType: defaultPubSubContentType,
+			message:     utils.ToJSON(chunk),
+		}
+
+		res, err = t.publish(fragment)
+		if err != nil {
+			err = fmt.Errorf(
+				"failed to publish chunk:%d/%d messageID:%s err:%w",
+				chunk.Sequence+1,
+				chunk.Total,
+				chunk.MessageID,
+				err,
+			)
+			return
+		}
+	}
+
+	return
+}
+
+// ReassembledMessage a PubSub message returned by a PubSubReassembler
+type ReassembledMessage struct {
+	// MessageID the chunked message ID, empty for unchunked messages
+	MessageID   string
+	ContentType string
+	Message     []byte
+}
+
+// PubSubReassembler reconstructs chunked PubSub messages
+// from their PubSubChunk fragments.
+type PubSubReassembler struct {
+	// TTL the time an incomplete message is kept
+	// before being discarded, defaults to 1 minute.
+	TTL time.Duration
+
+	mu      sync.Mutex
+	pending map[string]*pendingChunks
+}
+
+type pendingChunks struct {
+	contentType string
+	parts       [][]byte
+	received    int
+	created     time.Time
+}
+
+// NewPubSubReassembler create a reassembler for chunked PubSub messages
+func NewPubSubReassembler() *PubSubReassembler {
+	return &PubSubReassembler{
+		TTL:     defaultReassemblerTTL,
+		pending: map[string]*pendingChunks{},
+	}
+}
+
+// Add processes a received PubSub message. Once every fragment of a chunked
+// message has been received the original message is returned, until then a
+// nil message is returned. Messages which are not PubSubChunk fragments are
+// returned as is.
+func (r *PubSubReassembler) Add(message []byte) (msg *ReassembledMessage, err error) {
+	chunk := &PubSubChunk{}
+	if json.Unmarshal(message, chunk) != nil || chunk.Type != PubSubChunkType {
+		msg = &ReassembledMessage{Message: message}
+		return
+	}
+
+	if chunk.MessageID == "" || chunk.Total <= 0 || chunk.Sequence < 0 || chunk.Sequence >= chunk.Total {
+		err = fmt.Errorf(
+			"invalid pubsub chunk messageID:%q seq:%d total:%d",
+			chunk.MessageID,
+			chunk.Sequence,
+			chunk.Total,
+		)
+		return
+	}
+
+	data, err := base64.StdEncoding.DecodeString(chunk.Data)
+	if err != nil {
+		err = fmt.Errorf("invalid pubsub chunk data messageID:%s err:%s", chunk.MessageID, err)
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.pending == nil {
+		r.pending = map[string]*pendingChunks{}
+	}
+	r.expire()
+
+	pending, ok := r.pending[chunk.MessageID]
+	if !ok {
+		pending = &pendingChunks{
+			contentType: chunk.ContentType,
+			parts:       make([][]byte, chunk.Total),
+			created:     time.Now(),
+		}
+		r.pending[chunk.MessageID] = pending
+	}
+
+	if len(pending.parts) != chunk.Total {
+		delete(r.pending, chunk.MessageID)
+		err = fmt.Errorf("inconsistent pubsub chunk total messageID:%s", chunk.MessageID)
+		return
+	}
+
+	if pending.parts[chunk.Sequence] == nil {
+		pending.parts[chunk.Sequence] = data
+		pending.received++
+	}
+
+	if pending.received < chunk.Total {
+		return
+	}
+	delete(r.pending, chunk.MessageID)
+
+	msg = &ReassembledMessage{
+		MessageID:   chunk.MessageID,
+		ContentType: pending.contentType,
+	}
+	for _, part := range pending.parts {
+		msg.Message = append(msg.Message, part...)
+	}
+
+	return
+}
+
+// Pending returns the number of incomplete messages
+func (r *PubSubReassembler) Pending() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return len(r.pending)
+}
+
+func (r *PubSubReassembler) expire() {
+	ttl := r.TTL
+	if ttl <= 0 {
+		ttl = defaultReassemblerTTL
+	}
+
+	for id, pending := range r.pending {
+		if time.Since(pending.created) > ttl {
+			delete(r.pending, id)
+		}
+	}
+}
@@ -0,0 +1,240 @@
+package twitchext
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// Envelope the standard wrapper of a typed PubSub event,
+// Data contains the JSON encoded event value.
+type Envelope struct {
+	Event     string          `json:"event"`
+	Version   int             `json:"version"`
+	Timestamp time.Time       `json:"timestamp"`
+	MessageID string          `json:"message_id"`
+	Sender    string          `json:"sender,omitempty"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// UnknownEventError is returned when dispatching an envelope
+// whose event name and version have not been registered.
+type UnknownEventError struct {
+	Event   string
+	Version int
+}
+
+func (e *UnknownEventError) Error() string {
+	return fmt.Sprintf("unknown event:%q version:%d", e.Event, e.Version)
+}
+
+type eventName struct {
+	event   string
+	version int
+}
+
+type eventHandler struct {
+	fn      reflect.Value
+	pointer bool
+}
+
+// EventRegistry maps Go types to versioned event names, wrapping values in
+// envelopes when publishing and dispatching received envelopes to handlers.
+type EventRegistry struct {
+	// Sender is set on every envelope created by the registry
+	Sender string
+
+	mu       sync.RWMutex
+	names    map[reflect.Type]eventName
+	types    map[eventName]reflect.Type
+	handlers map[reflect.Type]*eventHandler
+}
+
+// NewEventRegistry create an event registry, the sender identifies
+// the origin of envelopes, e.g. the EBS instance name.
+func NewEventRegistry(sender string) *EventRegistry {
+	return &EventRegistry{
+		Sender:   sender,
+		names:    map[reflect.Type]eventName{},
+		types:    map[eventName]reflect.Type{},
+		handlers: map[reflect.Type]*eventHandler{},
+	}
+}
+
+func eventType(v interface{}) reflect.Type {
+	typ := reflect.TypeOf(v)
+	for typ != nil && typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	return typ
+}
+
+// Register maps the Go type of sample to an event name and schema version.
+// Each type and each event name and version pair may only be registered once.
+func (r *EventRegistry) Register(event string, version int, sample interface{}) error {
+	if event == "" {
+		return fmt.Errorf("missing event name")
+	}
+
+	typ := eventType(sample)
+	if typ == nil {
+		return fmt.Errorf("event:%q missing sample type", event)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	name := eventName{event: event, version: version}
+	if existing, ok := r.names[typ]; ok {
+		return fmt.Errorf("type:%s already registered as event:%q version:%d", typ, existing.event, existing.version)
+	}
+	if existing, ok := r.types[name]; ok {
+		return fmt.Errorf("event:%q version:%d already registered to type:%s", event, version, existing)
+	}
+
+	r.names[typ] = name
+	r.types[name] = typ
+
+	return nil
+}
+
+// Wrap creates an envelope for a value of a registered type
+func (r *EventRegistry) Wrap(v interface{}) (envelope *Envelope, err error) {
+	typ := eventType(v)
+
+	r.mu.RLock()
+	name, ok := r.names[typ]
+	r.mu.RUnlock()
+
+	if !ok {
+		err = fmt.Errorf("type:%s is not a registered event", typ)
+		return
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		err = fmt.Errorf("failed to marshal event:%q err:%s", name.event, err)
+		return
+	}
+
+	envelope = &Envelope{
+		Event:     name.event,
+		Version:   name.version,
+		Timestamp: time.Now().UTC(),
+		MessageID: newMessageID(),
+		Sender:    r.Sender,
+		Data:      data,
+	}
+
+	return
+}
+
+// Handle registers a typed handler for a registered event type.
+// The handler must have the signature func(*Envelope, T) error,
+// where T, or *T, is a registered event type.
+func (r *EventRegistry) Handle(handler interface{}) error {
+	fn := reflect.ValueOf(handler)
+	if !fn.IsValid() {
+		return fmt.Errorf("missing handler")
+	}
+	fnType := fn.Type()
+
+	errorType := reflect.TypeOf((*error)(nil)).Elem()
+	if fnType.Kind() != reflect.Func ||
+		fnType.NumIn() != 2 ||
+		fnType.In(0) != reflect.TypeOf(&Envelope{}) ||
+		fnType.NumOut() != 1 ||
+		fnType.Out(0) != errorType {
+		return fmt.Errorf("handler must have the signature func(*Envelope, T) error")
+	}
+
+	arg := fnType.In(1)
+	typ := arg
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.names[typ]; !ok {
+		return fmt.Errorf("type:%s is not a registered event", typ)
+	}
+
+	r.handlers[typ] = &eventHandler{fn: fn, pointer: arg.Kind() == reflect.Ptr}
+
+	return nil
+}
+
+// Dispatch decodes a JSON envelope and calls the handler of its event type.
+// An UnknownEventError is returned for unregistered events, events without
+// a handler are ignored.
+func (r *EventRegistry) Dispatch(message []byte) (err error) {
+	envelope := &Envelope{}
+	err = json.Unmarshal(message, envelope)
+	if err != nil {
+		err = fmt.Errorf("failed to decode envelope err:%s", err)
+		return
+	}
+
+	r.mu.RLock()
+	typ, ok := r.types[eventName{event: envelope.Event, version: envelope.Version}]
+	handler := r.handlers[typ]
+	r.mu.RUnlock()
+
+	if !ok {
+		err = &UnknownEventError{Event: envelope.Event, Version: envelope.Version}
+		return
+	}
+	if handler == nil {
+		return
+	}
+
+	value := reflect.New(typ)
+	err = json.Unmarshal(envelope.Data, value.Interface())
+	if err != nil {
+		err = fmt.Errorf(
+			"failed to decode event:%q version:%d err:%s",
+			envelope.Event,
+			envelope.Version,
+			err,
+		)
+		return
+	}
+
+	if !handler.pointer {
+		value = value.Elem()
+	}
+
+	out := handler.fn.Call([]reflect.Value{reflect.ValueOf(envelope), value})
+	if !out[0].IsNil() {
+		err = out[0].Interface().(error)
+	}
+
+	return
+}
+
+// Event sets the message to an envelope wrapping v, using the event
+// name and version v is registered to within the registry.
+func (m *PubSubMessage) Event(registry *EventRegistry, v interface{}) *PubSubMessage {
+	envelope, err := registry.Wrap(v)
+	if err != nil {
+		m.err = err
+		return m
+	}
+	return m.JSON(envelope)
+}
+
+// PublishChannelEvent publish a registered event to
+// a specific channel with the twitch extension enabled.
+func (t *Twitch) PublishChannelEvent(registry *EventRegistry, channelID string, v interface{}) (res *ResponseCommon, err error) {
+	return t.Publish(NewPubSubMessage(channelID).Broadcast().Event(registry, v))
+}
+
+// PublishGlobalEvent publish a registered event to
+// all channels with the twitch extension enabled.
+func (t *Twitch) PublishGlobalEvent(registry *EventRegistry, v interface{}) (res *ResponseCommon, err error) {
+	return t.Publish(NewPubSubMessage("").Global().Event(registry, v))
+}
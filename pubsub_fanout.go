@@ -0,0 +1,248 @@
+package twitchext
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	defaultFanOutConcurrency = 10
+	defaultFanOutRetries     = 1
+	defaultFanOutRetryWait   = time.Second
+)
+
+// FanOutStatus the outcome of publishing to a single channel
+type FanOutStatus string
+
+// Types of fan-out publish outcomes
+const (
+	FanOutSuccess     FanOutStatus = "success"
+	FanOutRateLimited FanOutStatus = "rate_limited"
+	FanOutFailed      FanOutStatus = "failed"
+)
+
+// FanOutOptions optional parameters for PublishToChannels.
+// Zero values use the defaults, negative values
+// turn the corresponding limit or delay off.
+type FanOutOptions struct {
+	// Concurrency the maximum number of channels published to
+	// at once, defaults to 10, negative publishes to every channel at once.
+	Concurrency int
+	// RateLimitRetries the number of times a rate limited
+	// channel is retried, defaults to 1, negative disables retries.
+	RateLimitRetries int
+	// RetryWait the time to wait before retrying a rate limited
+	// channel, defaults to 1 second, negative retries immediately.
+	RetryWait time.Duration
+}
+
+// settings the concurrency, retries and retry wait of the options
+// for publishing n messages, applying defaults and disabled values.
+func (o *FanOutOptions) settings(n int) (concurrency int, retries int, wait time.Duration) {
+	concurrency = o.Concurrency
+	switch {
+	case concurrency == 0:
+		concurrency = defaultFanOutConcurrency
+	case concurrency < 0:
+		concurrency = n
+	}
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+
+	retries = o.RateLimitRetries
+	switch {
+	case retries == 0:
+		retries = defaultFanOutRetries
+	case retries < 0:
+		retries = 0
+	}
+
+	wait = o.RetryWait
+	switch {
+	case wait == 0:
+		wait = defaultFanOutRetryWait
+	case wait < 0:
+		wait = 0
+	}
+
+	return
+}
+
+// FanOutResult the outcome of publishing to a channel,
+// Err is a *RateLimitError when the channel was rate limited.
+type FanOutResult struct {
+	ChannelID string
+	Status    FanOutStatus
+	Attempts  int
+	Err       error
+	Response  *ResponseCommon
+}
+
+// FanOutReport the per-channel results of PublishToChannels,
+// in the order the channels were requested, and aggregate counts.
+type FanOutReport struct {
+	Results     []*FanOutResult
+	Succeeded   int
+	RateLimited int
+	Failed      int
+	Duration    time.Duration
+}
+
+// PublishToChannels publishes the same broadcast message to each of the
+// channels, with bounded concurrency. Duplicate channel IDs are only
+// published to once and rate limited channels are retried after waiting.
+// An error is only returned when the message cannot be encoded, per-channel
+// failures are reported within the FanOutReport.
+func (t *Twitch) PublishToChannels(
+	channelIDs []string,
+	i interface{},
+	opts ...*FanOutOptions,
+) (
+	report *FanOutReport,
+	err error,
+) {
+	options := &FanOutOptions{}
+	if len(opts) > 0 && opts[0] != nil {
+		options = opts[0]
+	}
+
+	data, err := json.Marshal(i)
+	if err != nil {
+		err = fmt.Errorf("failed to marshal pubsub message err:%s", err)
+		return
+	}
+	message := string(data)
+
+	report = &FanOutReport{}
+	seen := map[string]bool{}
+	for _, channelID := range channelIDs {
+		if seen[channelID] {
+			continue
+		}
+		seen[channelID] = true
+		report.Results = append(report.Results, &FanOutResult{ChannelID: channelID})
+	}
+
+	concurrency, retries, wait := options.settings(len(report.Results))
+
+	started := time.Now()
+	semaphore := make(chan struct{}, concurrency)
+	wg := sync.WaitGroup{}
+
+	for _, result := range report.Results {
+		wg.Add(1)
+		semaphore <- struct{}{}
+
+		go func(result *FanOutResult) {
+			defer func() {
+				<-semaphore
+				wg.Done()
+			}()
+
+			t.fanOutPublish(result, message, retries, wait)
+		}(result)
+	}
+	wg.Wait()
+
+	report.Duration = time.Since(started)
+	for _, result := range report.Results {
+		switch result.Status {
+		case FanOutSuccess:
+			report.Succeeded++
+		case FanOutRateLimited:
+			report.RateLimited++
+		default:
+			report.Failed++
+		}
+	}
+
+	return
+}
+
+func (t *Twitch) fanOutPublish(result *FanOutResult, message string, retries int, wait time.Duration) {
+	result.Response, result.Status, result.Attempts, result.Err = t.publishWithRetry(
+		NewPubSubMessage(result.ChannelID).Broadcast().Raw(message),
+		retries,
+		wait,
+		nil,
+	)
+}
+
+// publishPacer spaces the messages published to a
+// single channel at least the interval apart.
+type publishPacer struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+// wait blocks until the next message may be published
+func (p *publishPacer) wait() {
+	p.mu.Lock()
+	at := p.next
+	if now := time.Now(); at.Before(now) {
+		at = now
+	}
+	p.next = at.Add(p.interval)
+	p.mu.Unlock()
+
+	time.Sleep(time.Until(at))
+}
+
+// hold delays every following message until d has passed
+func (p *publishPacer) hold(d time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if until := time.Now().Add(d); p.next.Before(until) {
+		p.next = until
+	}
+}
+
+// publishWithRetry publishes the message, retrying up to retries times
+// when rate limited after waiting. Messages sharing a pacer wait their
+// turn before each attempt, and all wait once any of them is rate limited.
+func (t *Twitch) publishWithRetry(
+	msg *PubSubMessage,
+	retries int,
+	wait time.Duration,
+	pacer *publishPacer,
+) (
+	res *ResponseCommon,
+	status FanOutStatus,
+	attempts int,
+	err error,
+) {
+	for {
+		if pacer != nil {
+			pacer.wait()
+		}
+
+		attempts++
+		res, err = t.Publish(msg)
+
+		var rateLimited *RateLimitError
+		switch {
+		case err == nil:
+			status = FanOutSuccess
+			return
+		case errors.As(err, &rateLimited):
+			status = FanOutRateLimited
+			if attempts > retries {
+				return
+			}
+			if pacer != nil {
+				pacer.hold(wait)
+				continue
+			}
+			time.Sleep(wait)
+		default:
+			status = FanOutFailed
+			return
+		}
+	}
+}
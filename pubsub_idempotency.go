@@ -0,0 +1,178 @@
+package twitchext
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jackmcguire1/go-twitch-ext/internal/utils"
+)
+
+const defaultIdempotencyTTL = 5 * time.Minute
+
+// ErrDuplicatePublish is matched by errors.Is when a publish is
+// suppressed because its idempotency key was already sent.
+var ErrDuplicatePublish = errors.New("duplicate pubsub publish")
+
+// DuplicatePublishError is returned when a message is not published
+// because a message with the same idempotency key was recently
+// sent, or is currently being sent.
+type DuplicatePublishError struct {
+	Key string
+}
+
+func (e *DuplicatePublishError) Error() string {
+	return fmt.Sprintf("duplicate pubsub publish idempotencyKey:%s", e.Key)
+}
+
+// Is reports the error as ErrDuplicatePublish
+func (e *DuplicatePublishError) Is(target error) bool {
+	return target == ErrDuplicatePublish
+}
+
+// IdempotentMessage the JSON marker wrapping messages published with an
+// idempotency key. Viewers should drop messages whose key they have
+// already processed and then handle Message using ContentType.
+type IdempotentMessage struct {
+	IdempotencyKey string `json:"idempotency_key"`
+	ContentType    string `json:"content_type"`
+	Message        string `json:"message"`
+}
+
+// UnwrapIdempotentMessage decodes an idempotency marker from a received
+// PubSub message, ok is false when the message has no marker.
+func UnwrapIdempotentMessage(message []byte) (msg *IdempotentMessage, ok bool) {
+	msg = &IdempotentMessage{}
+	if json.Unmarshal(message, msg) != nil || msg.IdempotencyKey == "" {
+		return nil, false
+	}
+	return msg, true
+}
+
+// IdempotencyCache a short-lived record of idempotency keys
+type IdempotencyCache struct {
+	ttl time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*idempotencyEntry
+}
+
+type idempotencyEntry struct {
+	expires time.Time
+	pending bool
+}
+
+// NewIdempotencyCache create a cache remembering keys for the
+// given duration, a non-positive ttl defaults to 5 minutes.
+func NewIdempotencyCache(ttl time.Duration) *IdempotencyCache {
+	if ttl <= 0 {
+		ttl = defaultIdempotencyTTL
+	}
+
+	return &IdempotencyCache{
+		ttl:     ttl,
+		entries: map[string]*idempotencyEntry{},
+	}
+}
+
+// Seen records the key, reporting whether it had already been recorded.
+// Go based viewers can use it to drop duplicate IdempotentMessages.
+func (c *IdempotencyCache) Seen(key string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.expire()
+	if _, ok := c.entries[key]; ok {
+		return true
+	}
+	c.entries[key] = &idempotencyEntry{expires: time.Now().Add(c.ttl)}
+
+	return false
+}
+
+// reserve marks the key as being sent, returning
+// false when it is already pending or was sent.
+func (c *IdempotencyCache) reserve(key string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.expire()
+	if _, ok := c.entries[key]; ok {
+		return false
+	}
+	c.entries[key] = &idempotencyEntry{expires: time.Now().Add(c.ttl), pending: true}
+
+	return true
+}
+
+// commit records the key as sent
+func (c *IdempotencyCache) commit(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries[key] = &idempotencyEntry{expires: time.Now().Add(c.ttl)}
+}
+
+// release forgets a key whose send failed, allowing a retry
+func (c *IdempotencyCache) release(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if ok && entry.pending {
+		delete(c.entries, key)
+	}
+}
+
+func (c *IdempotencyCache) expire() {
+	now := time.Now()
+	for key, entry := range c.entries {
+		if !entry.pending && now.After(entry.expires) {
+			delete(c.entries, key)
+		}
+	}
+}
+
+// IdempotencyKey sets a key identifying the message across retries.
+// The message is wrapped within an IdempotentMessage marker, and
+// publishing a key already sent by this client within the
+// idempotency TTL is suppressed with a DuplicatePublishError.
+// Failed publishes, including timeouts, release the key so they
+// may be retried, viewers use the marker to drop any duplicates.
+func (m *PubSubMessage) IdempotencyKey(key string) *PubSubMessage {
+	m.idempotency = key
+	return m
+}
+
+func (t *Twitch) publishIdempotent(msg *PubSubMessage) (res *ResponseCommon, err error) {
+	key := msg.idempotency
+
+	if t.idempotency != nil {
+		if !t.idempotency.reserve(key) {
+			err = &DuplicatePublishError{Key: key}
+			return
+		}
+	}
+
+	wrapped := *msg
+	wrapped.contentType = defaultPubSubContentType
+	wrapped.message = utils.ToJSON(&IdempotentMessage{
+		IdempotencyKey: key,
+		ContentType:    msg.contentType,
+		Message:        msg.message,
+	})
+
+	res, err = t.send(&wrapped)
+
+	if t.idempotency != nil {
+		if err != nil {
+			t.idempotency.release(key)
+		} else {
+			t.idempotency.commit(key)
+		}
+	}
+
+	return
+}
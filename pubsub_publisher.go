@@ -0,0 +1,208 @@
+package twitchext
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultChannelPublishInterval = time.Second
+	defaultChannelPublishMaxQueue = 100
+)
+
+// ChannelPublisherOptions optional parameters for the ChannelPublisher
+type ChannelPublisherOptions struct {
+	// Interval the minimum time between messages published
+	// to the same channel, defaults to 1 second.
+	Interval time.Duration
+	// MaxQueue the maximum number of messages queued per channel,
+	// the oldest message is dropped once exceeded, defaults to 100.
+	MaxQueue int
+	// OnError is called when a queued message fails to publish
+	OnError func(channelID string, err error)
+}
+
+// ChannelPublisherStats counters reported by the ChannelPublisher
+type ChannelPublisherStats struct {
+	Published uint64
+	Coalesced uint64
+	Dropped   uint64
+	Failed    uint64
+}
+
+// ChannelPublisher queues broadcast messages per channel and publishes
+// them with PublishChannelNotification at the rate allowed by Twitch.
+// Queued messages sharing a key are coalesced, the latest message
+// replacing the earlier one while keeping its place in the queue.
+type ChannelPublisher struct {
+	published uint64
+	coalesced uint64
+	dropped   uint64
+	failed    uint64
+
+	twitch   *Twitch
+	interval time.Duration
+	maxQueue int
+	onError  func(channelID string, err error)
+
+	mu     sync.Mutex
+	queues map[string]*channelQueue
+	closed bool
+	wg     sync.WaitGroup
+}
+
+type channelQueue struct {
+	entries  []*queuedMessage
+	running  bool
+	lastSent time.Time
+}
+
+type queuedMessage struct {
+	key  string
+	data interface{}
+}
+
+// NewChannelPublisher create a rate limited, coalescing channel publisher
+func (t *Twitch) NewChannelPublisher(opts ...*ChannelPublisherOptions) *ChannelPublisher {
+	p := &ChannelPublisher{
+		twitch:   t,
+		interval: defaultChannelPublishInterval,
+		maxQueue: defaultChannelPublishMaxQueue,
+		queues:   map[string]*channelQueue{},
+	}
+
+	if len(opts) > 0 && opts[0] != nil {
+		if opts[0].Interval > 0 {
+			p.interval = opts[0].Interval
+		}
+		if opts[0].MaxQueue > 0 {
+			p.maxQueue = opts[0].MaxQueue
+		}
+		p.onError = opts[0].OnError
+	}
+
+	return p
+}
+
+// Publish queues a message for the channel. A non-empty key marks the
+// message as superseding any queued message for the channel with the
+// same key, an empty key is never coalesced.
+func (p *ChannelPublisher) Publish(channelID string, key string, data interface{}) (err error) {
+	if channelID == "" {
+		err = fmt.Errorf("missing channelID")
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		err = fmt.Errorf("channel publisher closed")
+		return
+	}
+
+	queue, ok := p.queues[channelID]
+	if !ok {
+		queue = &channelQueue{}
+		p.queues[channelID] = queue
+	}
+
+	if key != "" {
+		for _, entry := range queue.entries {
+			if entry.key == key {
+				entry.data = data
+				atomic.AddUint64(&p.coalesced, 1)
+				return
+			}
+		}
+	}
+
+	queue.entries = append(queue.entries, &queuedMessage{key: key, data: data})
+	if len(queue.entries) > p.maxQueue {
+		queue.entries = queue.entries[1:]
+		atomic.AddUint64(&p.dropped, 1)
+	}
+
+	if !queue.running {
+		queue.running = true
+		p.wg.Add(1)
+		go p.drain(channelID, queue)
+	}
+
+	return
+}
+
+// Stats returns the publish, coalesce, drop and failure counts
+func (p *ChannelPublisher) Stats() ChannelPublisherStats {
+	return ChannelPublisherStats{
+		Published: atomic.LoadUint64(&p.published),
+		Coalesced: atomic.LoadUint64(&p.coalesced),
+		Dropped:   atomic.LoadUint64(&p.dropped),
+		Failed:    atomic.LoadUint64(&p.failed),
+	}
+}
+
+// Pending returns the number of messages queued for the channel
+func (p *ChannelPublisher) Pending(channelID string) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	queue, ok := p.queues[channelID]
+	if !ok {
+		return 0
+	}
+	return len(queue.entries)
+}
+
+// Close stops accepting messages and waits
+// for the queued messages to be published.
+func (p *ChannelPublisher) Close() {
+	p.mu.Lock()
+	p.closed = true
+	p.mu.Unlock()
+
+	p.wg.Wait()
+}
+
+func (p *ChannelPublisher) drain(channelID string, queue *channelQueue) {
+	defer p.wg.Done()
+
+	for {
+		p.mu.Lock()
+
+		// the head of the queue is only taken once the channel
+		// may be published to, so it can still be coalesced.
+		// An empty queue is kept until the interval has elapsed
+		// so messages arriving meanwhile are still rate limited.
+		wait := time.Until(queue.lastSent.Add(p.interval))
+		if wait > 0 {
+			p.mu.Unlock()
+			time.Sleep(wait)
+			continue
+		}
+
+		if len(queue.entries) == 0 {
+			queue.running = false
+			delete(p.queues, channelID)
+			p.mu.Unlock()
+			return
+		}
+
+		entry := queue.entries[0]
+		queue.entries = queue.entries[1:]
+		queue.lastSent = time.Now()
+		p.mu.Unlock()
+
+		_, err := p.twitch.PublishChannelNotification(channelID, entry.data)
+		if err != nil {
+			atomic.AddUint64(&p.failed, 1)
+			if p.onError != nil {
+				p.onError(channelID, err)
+			}
+			continue
+		}
+		atomic.AddUint64(&p.published, 1)
+	}
+}
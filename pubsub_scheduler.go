@@ -0,0 +1,438 @@
+package twitchext
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	defaultSchedulerRetries   = 3
+	defaultSchedulerRetryWait = time.Second
+	maxSchedulerRetryWait     = time.Minute
+)
+
+// ScheduledJob a PubSub message to be published at a later time.
+// Jobs without a channel ID are published globally, jobs with an
+// opaque user ID are whispered to that user.
+type ScheduledJob struct {
+	ID           string    `json:"id"`
+	FireAt       time.Time `json:"fire_at"`
+	ChannelID    string    `json:"channel_id,omitempty"`
+	OpaqueUserID string    `json:"opaque_user_id,omitempty"`
+	// Key the coalescing key used when publishing
+	// broadcasts through a ChannelPublisher.
+	Key     string          `json:"key,omitempty"`
+	Message json.RawMessage `json:"message"`
+	// Attempts the number of times the job failed to publish
+	Attempts int `json:"attempts,omitempty"`
+}
+
+// ScheduleStore persists pending scheduled jobs,
+// allowing them to survive restarts.
+type ScheduleStore interface {
+	Save(job *ScheduledJob) error
+	Delete(id string) error
+	List() ([]*ScheduledJob, error)
+}
+
+// MemoryScheduleStore an in-memory ScheduleStore,
+// used by default when no store is configured.
+type MemoryScheduleStore struct {
+	mu   sync.Mutex
+	jobs map[string]*ScheduledJob
+}
+
+// NewMemoryScheduleStore create an in-memory schedule store
+func NewMemoryScheduleStore() *MemoryScheduleStore {
+	return &MemoryScheduleStore{jobs: map[string]*ScheduledJob{}}
+}
+
+// Save stores the job, replacing any job with the same ID
+func (s *MemoryScheduleStore) Save(job *ScheduledJob) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	copied := *job
+	s.jobs[job.ID] = &copied
+	return nil
+}
+
+// Delete removes the job
+func (s *MemoryScheduleStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.jobs, id)
+	return nil
+}
+
+// List returns every stored job
+func (s *MemoryScheduleStore) List() ([]*ScheduledJob, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	jobs := make([]*ScheduledJob, 0, len(s.jobs))
+	for _, job := range s.jobs {
+		copied := *job
+		jobs = append(jobs, &copied)
+	}
+	return jobs, nil
+}
+
+// SchedulerOptions optional parameters for the Scheduler
+type SchedulerOptions struct {
+	// Store persists pending jobs, defaults to a MemoryScheduleStore
+	Store ScheduleStore
+	// Publisher rate limits channel broadcasts when set,
+	// otherwise jobs are published with PublishChannelNotification.
+	Publisher *ChannelPublisher
+	// MaxRetries the number of times a job failing to publish is
+	// retried before it is removed, defaults to 3, negative disables retries.
+	MaxRetries int
+	// RetryWait the time before a failed job is first retried, doubling
+	// with each attempt up to 1 minute, defaults to 1 second. Rate limited
+	// jobs are retried once the reported rate limit resets.
+	RetryWait time.Duration
+	// OnError is called when a fired job fails to publish
+	// and is removed once its retries are exhausted.
+	OnError func(job *ScheduledJob, err error)
+}
+
+// Scheduler publishes PubSub messages at scheduled times
+type Scheduler struct {
+	twitch    *Twitch
+	store     ScheduleStore
+	publisher *ChannelPublisher
+	retries   int
+	retryWait time.Duration
+	onError   func(job *ScheduledJob, err error)
+
+	mu     sync.Mutex
+	jobs   map[string]*ScheduledJob
+	closed bool
+	wake   chan struct{}
+	done   chan struct{}
+	wg     sync.WaitGroup
+}
+
+// NewScheduler create a scheduler, restoring any pending
+// jobs from the store. Jobs whose fire time has already
+// passed are published immediately.
+func (t *Twitch) NewScheduler(opts ...*SchedulerOptions) (s *Scheduler, err error) {
+	s = &Scheduler{
+		twitch:    t,
+		store:     NewMemoryScheduleStore(),
+		retries:   defaultSchedulerRetries,
+		retryWait: defaultSchedulerRetryWait,
+		jobs:      map[string]*ScheduledJob{},
+		wake:      make(chan struct{}, 1),
+		done:      make(chan struct{}),
+	}
+
+	if len(opts) > 0 && opts[0] != nil {
+		if opts[0].Store != nil {
+			s.store = opts[0].Store
+		}
+		switch {
+		case opts[0].MaxRetries > 0:
+			s.retries = opts[0].MaxRetries
+		case opts[0].MaxRetries < 0:
+			s.retries = 0
+		}
+		if opts[0].RetryWait > 0 {
+			s.retryWait = opts[0].RetryWait
+		}
+		s.publisher = opts[0].Publisher
+		s.onError = opts[0].OnError
+	}
+
+	jobs, err := s.store.List()
+	if err != nil {
+		err = fmt.Errorf("failed to restore scheduled jobs err:%s", err)
+		s = nil
+		return
+	}
+	for _, job := range jobs {
+		s.jobs[job.ID] = job
+	}
+
+	s.wg.Add(1)
+	go s.run()
+
+	return
+}
+
+// Schedule adds a job, generating an ID when the job has none.
+// Scheduling a job with the ID of a pending job replaces it.
+func (s *Scheduler) Schedule(job *ScheduledJob) (id string, err error) {
+	if len(job.Message) == 0 {
+		err = fmt.Errorf("scheduled job missing message")
+		return
+	}
+	if job.OpaqueUserID != "" && job.ChannelID == "" {
+		err = fmt.Errorf("scheduled whisper missing channelID")
+		return
+	}
+
+	scheduled := *job
+	if scheduled.ID == "" {
+		scheduled.ID = newMessageID()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		err = fmt.Errorf("scheduler closed")
+		return
+	}
+
+	err = s.store.Save(&scheduled)
+	if err != nil {
+		return
+	}
+	s.jobs[scheduled.ID] = &scheduled
+	s.notify()
+
+	id = scheduled.ID
+
+	return
+}
+
+// ScheduleChannel schedules a broadcast to the channel at the given time
+func (s *Scheduler) ScheduleChannel(channelID string, fireAt time.Time, i interface{}) (id string, err error) {
+	if channelID == "" {
+		err = fmt.Errorf("missing channelID")
+		return
+	}
+
+	data, err := json.Marshal(i)
+	if err != nil {
+		err = fmt.Errorf("failed to marshal scheduled message err:%s", err)
+		return
+	}
+
+	return s.Schedule(&ScheduledJob{ChannelID: channelID, FireAt: fireAt, Message: data})
+}
+
+// ScheduleGlobal schedules a global message at the given time
+func (s *Scheduler) ScheduleGlobal(fireAt time.Time, i interface{}) (id string, err error) {
+	data, err := json.Marshal(i)
+	if err != nil {
+		err = fmt.Errorf("failed to marshal scheduled message err:%s", err)
+		return
+	}
+
+	return s.Schedule(&ScheduledJob{FireAt: fireAt, Message: data})
+}
+
+// Cancel removes a pending job
+func (s *Scheduler) Cancel(id string) (err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.jobs[id]; !ok {
+		err = fmt.Errorf("scheduled job:%s not found", id)
+		return
+	}
+
+	err = s.store.Delete(id)
+	if err != nil {
+		return
+	}
+	delete(s.jobs, id)
+	s.notify()
+
+	return
+}
+
+// Reschedule changes the fire time of a pending job
+func (s *Scheduler) Reschedule(id string, fireAt time.Time) (err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		err = fmt.Errorf("scheduler closed")
+		return
+	}
+
+	job, ok := s.jobs[id]
+	if !ok {
+		err = fmt.Errorf("scheduled job:%s not found", id)
+		return
+	}
+
+	rescheduled := *job
+	rescheduled.FireAt = fireAt
+
+	err = s.store.Save(&rescheduled)
+	if err != nil {
+		return
+	}
+	s.jobs[id] = &rescheduled
+	s.notify()
+
+	return
+}
+
+// Jobs returns the pending jobs ordered by fire time
+func (s *Scheduler) Jobs() []*ScheduledJob {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	jobs := make([]*ScheduledJob, 0, len(s.jobs))
+	for _, job := range s.jobs {
+		copied := *job
+		jobs = append(jobs, &copied)
+	}
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].FireAt.Before(jobs[j].FireAt)
+	})
+
+	return jobs
+}
+
+// Close stops the scheduler, pending jobs remain
+// within the store to be restored by a new scheduler.
+// Jobs can no longer be scheduled once closed.
+func (s *Scheduler) Close() {
+	s.mu.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.done)
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+}
+
+func (s *Scheduler) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (s *Scheduler) run() {
+	defer s.wg.Done()
+
+	timer := time.NewTimer(time.Hour)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		default:
+		}
+
+		s.mu.Lock()
+		var next *ScheduledJob
+		for _, job := range s.jobs {
+			if next == nil || job.FireAt.Before(next.FireAt) {
+				next = job
+			}
+		}
+
+		var due *ScheduledJob
+		wait := time.Hour
+		if next != nil {
+			wait = time.Until(next.FireAt)
+			if wait <= 0 {
+				due = next
+				delete(s.jobs, next.ID)
+			}
+		}
+		s.mu.Unlock()
+
+		if due != nil {
+			s.fire(due)
+			continue
+		}
+
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		timer.Reset(wait)
+
+		select {
+		case <-s.done:
+			return
+		case <-s.wake:
+		case <-timer.C:
+		}
+	}
+}
+
+func (s *Scheduler) fire(job *ScheduledJob) {
+	var err error
+
+	switch {
+	case job.ChannelID == "":
+		_, err = s.twitch.Publish(NewPubSubMessage("").Global().Raw(string(job.Message)))
+	case job.OpaqueUserID != "":
+		_, err = s.twitch.Publish(NewPubSubMessage(job.ChannelID).Whisper(job.OpaqueUserID).Raw(string(job.Message)))
+	case s.publisher != nil:
+		err = s.publisher.Publish(job.ChannelID, job.Key, job.Message)
+	default:
+		_, err = s.twitch.Publish(NewPubSubMessage(job.ChannelID).Broadcast().Raw(string(job.Message)))
+	}
+
+	// the job is removed from the store once published, so a
+	// restart before publishing results in the job firing again.
+	// A job scheduled again with the same ID while firing is kept,
+	// a failed job is kept until its retries are exhausted.
+	s.mu.Lock()
+	_, replaced := s.jobs[job.ID]
+	switch {
+	case replaced:
+		// the new job fires in place of this one
+	case err != nil && job.Attempts < s.retries:
+		retry := *job
+		retry.Attempts++
+		retry.FireAt = time.Now().Add(s.retryDelay(job.Attempts, err))
+
+		saveErr := s.store.Save(&retry)
+		if saveErr == nil {
+			s.jobs[retry.ID] = &retry
+			s.mu.Unlock()
+			return
+		}
+		err = fmt.Errorf("failed to reschedule job:%s err:%s publish err:%w", job.ID, saveErr, err)
+	default:
+		deleteErr := s.store.Delete(job.ID)
+		if err == nil && deleteErr != nil {
+			err = fmt.Errorf("failed to delete fired job:%s err:%s", job.ID, deleteErr)
+		}
+	}
+	s.mu.Unlock()
+
+	if err != nil && s.onError != nil {
+		s.onError(job, err)
+	}
+}
+
+// retryDelay the time to wait before retrying a job which failed
+// to publish, waiting for the rate limit to reset when rate limited.
+func (s *Scheduler) retryDelay(attempts int, err error) time.Duration {
+	wait := s.retryWait
+	for i := 0; i < attempts && wait < maxSchedulerRetryWait; i++ {
+		wait *= 2
+	}
+	if wait > maxSchedulerRetryWait {
+		wait = maxSchedulerRetryWait
+	}
+
+	var rateLimited *RateLimitError
+	if errors.As(err, &rateLimited) {
+		return rateLimitWait(rateLimited.Headers, wait)
+	}
+	return wait
+}
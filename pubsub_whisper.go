@@ -0,0 +1,202 @@
+package twitchext
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const defaultWhisperTargetsPerMessage = 20
+
+// Whisper a message for a single user viewing the extension
+type Whisper struct {
+	OpaqueUserID string
+	Data         interface{}
+}
+
+// BulkWhisperOptions optional parameters for PublishWhispers
+type BulkWhisperOptions struct {
+	FanOutOptions
+	// TargetsPerMessage the maximum number of whisper targets
+	// combined into a single message, defaults to 20.
+	TargetsPerMessage int
+	// Interval the minimum time between messages published to the
+	// channel, defaults to 1 second, negative disables pacing.
+	Interval time.Duration
+}
+
+// WhisperResult the delivery outcome for a single recipient
+type WhisperResult struct {
+	OpaqueUserID string
+	Status       FanOutStatus
+	Attempts     int
+	Err          error
+}
+
+// BulkWhisperReport the per-recipient results of PublishWhispers, in the
+// order the whispers were requested, and aggregate counts. A recipient
+// requested more than once with the same payload shares a single result
+// and is counted once.
+type BulkWhisperReport struct {
+	Results     []*WhisperResult
+	Messages    int
+	Succeeded   int
+	RateLimited int
+	Failed      int
+	Duration    time.Duration
+}
+
+type whisperBatch struct {
+	message string
+	results []*WhisperResult
+}
+
+// PublishWhispers delivers whispers to many users of a channel. Recipients
+// receiving an identical payload are grouped into multi-target messages,
+// distinct payloads are published concurrently within the configured
+// concurrency, paced to the channel rate limit like the ChannelPublisher,
+// and rate limited messages are retried after waiting.
+// An error is only returned when a payload cannot be encoded, delivery
+// failures are reported per recipient within the BulkWhisperReport.
+func (t *Twitch) PublishWhispers(
+	channelID string,
+	whispers []*Whisper,
+	opts ...*BulkWhisperOptions,
+) (
+	report *BulkWhisperReport,
+	err error,
+) {
+	if channelID == "" {
+		err = fmt.Errorf("missing channelID")
+		return
+	}
+
+	options := &BulkWhisperOptions{}
+	if len(opts) > 0 && opts[0] != nil {
+		options = opts[0]
+	}
+
+	perMessage := options.TargetsPerMessage
+	if perMessage <= 0 {
+		perMessage = defaultWhisperTargetsPerMessage
+	}
+
+	report = &BulkWhisperReport{}
+
+	// group recipients by payload, keeping the order payloads were first seen
+	var payloads []string
+	groups := map[string][]*WhisperResult{}
+	recipients := map[string]map[string]*WhisperResult{}
+
+	for _, whisper := range whispers {
+		if whisper.OpaqueUserID == "" {
+			err = fmt.Errorf("whisper missing opaque user ID")
+			return
+		}
+
+		var data []byte
+		data, err = json.Marshal(whisper.Data)
+		if err != nil {
+			err = fmt.Errorf(
+				"failed to marshal whisper opaqueUserID:%s err:%s",
+				whisper.OpaqueUserID,
+				err,
+			)
+			return
+		}
+		payload := string(data)
+
+		if _, ok := groups[payload]; !ok {
+			payloads = append(payloads, payload)
+			recipients[payload] = map[string]*WhisperResult{}
+		}
+
+		// the same user receiving the same payload twice shares a result
+		result, ok := recipients[payload][whisper.OpaqueUserID]
+		if !ok {
+			result = &WhisperResult{OpaqueUserID: whisper.OpaqueUserID}
+			recipients[payload][whisper.OpaqueUserID] = result
+			groups[payload] = append(groups[payload], result)
+		}
+		report.Results = append(report.Results, result)
+	}
+
+	var batches []*whisperBatch
+	for _, payload := range payloads {
+		group := groups[payload]
+		for start := 0; start < len(group); start += perMessage {
+			end := start + perMessage
+			if end > len(group) {
+				end = len(group)
+			}
+			batches = append(batches, &whisperBatch{message: payload, results: group[start:end]})
+		}
+	}
+	report.Messages = len(batches)
+
+	concurrency, retries, wait := options.settings(len(batches))
+
+	var pacer *publishPacer
+	switch {
+	case options.Interval == 0:
+		pacer = &publishPacer{interval: defaultChannelPublishInterval}
+	case options.Interval > 0:
+		pacer = &publishPacer{interval: options.Interval}
+	}
+
+	started := time.Now()
+	semaphore := make(chan struct{}, concurrency)
+	wg := sync.WaitGroup{}
+
+	for _, batch := range batches {
+		wg.Add(1)
+		semaphore <- struct{}{}
+
+		go func(batch *whisperBatch) {
+			defer func() {
+				<-semaphore
+				wg.Done()
+			}()
+
+			t.publishWhisperBatch(channelID, batch, retries, wait, pacer)
+		}(batch)
+	}
+	wg.Wait()
+
+	report.Duration = time.Since(started)
+	for _, payload := range payloads {
+		for _, result := range groups[payload] {
+			switch result.Status {
+			case FanOutSuccess:
+				report.Succeeded++
+			case FanOutRateLimited:
+				report.RateLimited++
+			default:
+				report.Failed++
+			}
+		}
+	}
+
+	return
+}
+
+func (t *Twitch) publishWhisperBatch(
+	channelID string,
+	batch *whisperBatch,
+	retries int,
+	wait time.Duration,
+	pacer *publishPacer,
+) {
+	msg := NewPubSubMessage(channelID).Raw(batch.message)
+	for _, result := range batch.results {
+		msg.Whisper(result.OpaqueUserID)
+	}
+
+	_, status, attempts, err := t.publishWithRetry(msg, retries, wait, pacer)
+	for _, result := range batch.results {
+		result.Status = status
+		result.Attempts = attempts
+		result.Err = err
+	}
+}
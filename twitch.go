@@ -2,6 +2,7 @@ package twitchext
 
 import (
 	"net/http"
+	"time"
 )
 
 // Twitch package struct
@@ -12,12 +13,53 @@ type Twitch struct {
 	ClientID      string
 	Version       string
 	ConfigVersion string
+
+	pubSubAPI       APIFamily
+	chatAPI         APIFamily
+	liveChannelsAPI APIFamily
+	chatFilters     []ChatFilter
+	idempotency     *IdempotencyCache
+	appToken        *appToken
 }
 
+// APIFamily the family of Twitch API endpoints used by the client
+type APIFamily string
+
+// Types of Twitch API endpoint families
+const (
+	// HelixAPI the Twitch Helix API, https://dev.twitch.tv/docs/api/reference
+	HelixAPI APIFamily = "helix"
+	// LegacyAPI the deprecated Twitch Extensions API, https://dev.twitch.tv/docs/extensions/reference
+	LegacyAPI APIFamily = "legacy"
+)
+
 // Options optional parameters for the
 // twitch ext client for configuration
 type Options struct {
 	Client *http.Client
+	// IdempotencyTTL how long published idempotency
+	// keys are remembered, defaults to 5 minutes.
+	IdempotencyTTL time.Duration
+	// PubSubAPI the endpoint family PubSub messages
+	// are sent to, defaults to HelixAPI.
+	PubSubAPI APIFamily
+	// ChatAPI the endpoint family chat messages
+	// are sent to, defaults to HelixAPI.
+	ChatAPI APIFamily
+	// ChatFilters are applied in order to every chat
+	// message before it is sent, see FilterChatMessage.
+	ChatFilters []ChatFilter
+	// LiveChannelsAPI the endpoint family live channels are requested
+	// from, defaults to HelixAPI when AppAccessToken or ClientSecret
+	// is set, otherwise to LegacyAPI.
+	LiveChannelsAPI APIFamily
+	// AppAccessToken an app access token used by Helix
+	// endpoints which do not accept extension JWTs.
+	AppAccessToken string
+	// ClientSecret the OAuth client secret of the extension, not the
+	// extension secret, used to request app access tokens when
+	// AppAccessToken is not set.
+	ClientSecret string
 }
 
 // NewClient create reference to twitch-ext package
@@ -33,19 +75,42 @@ func NewClient(
 	twitch *Twitch,
 ) {
 	twitch = &Twitch{
-		client:        &http.Client{},
-		OwnerID:       ownerID,
-		Secret:        secret,
-		ClientID:      clientID,
-		Version:       extVersion,
-		ConfigVersion: configVersion,
+		client:          &http.Client{},
+		OwnerID:         ownerID,
+		Secret:          secret,
+		ClientID:        clientID,
+		Version:         extVersion,
+		ConfigVersion:   configVersion,
+		pubSubAPI:       HelixAPI,
+		chatAPI:         HelixAPI,
+		liveChannelsAPI: LegacyAPI,
 	}
 
+	var idempotencyTTL time.Duration
+	twitch.appToken = &appToken{}
 	if len(opts) > 0 {
 		if opts[0].Client != nil {
 			twitch.client = opts[0].Client
 		}
+		idempotencyTTL = opts[0].IdempotencyTTL
+		if opts[0].PubSubAPI != "" {
+			twitch.pubSubAPI = opts[0].PubSubAPI
+		}
+		if opts[0].ChatAPI != "" {
+			twitch.chatAPI = opts[0].ChatAPI
+		}
+		twitch.chatFilters = opts[0].ChatFilters
+		twitch.appToken.static = opts[0].AppAccessToken
+		twitch.appToken.clientSecret = opts[0].ClientSecret
+		// the Helix live channels endpoint requires an app access token
+		if opts[0].AppAccessToken != "" || opts[0].ClientSecret != "" {
+			twitch.liveChannelsAPI = HelixAPI
+		}
+		if opts[0].LiveChannelsAPI != "" {
+			twitch.liveChannelsAPI = opts[0].LiveChannelsAPI
+		}
 	}
+	twitch.idempotency = NewIdempotencyCache(idempotencyTTL)
 
 	return
 }
@@ -0,0 +1,38 @@
+//go:build go1.23
+
+package twitchext
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLiveChannelsIterator(t *testing.T) {
+	assert := assert.New(t)
+
+	client, requests := liveChannelsClient()
+
+	var ids []string
+	for channel, err := range client.LiveChannels(context.Background(), nil) {
+		assert.NoError(err)
+		ids = append(ids, channel.ID)
+		if channel.ID == "3" {
+			break
+		}
+	}
+	assert.EqualValues([]string{"1", "2", "3"}, ids)
+	assert.EqualValues(3, requests())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var errs []error
+	for channel, err := range client.LiveChannels(ctx, nil) {
+		assert.Nil(channel)
+		errs = append(errs, err)
+	}
+	assert.Len(errs, 1)
+	assert.ErrorIs(errs[0], context.Canceled)
+}
@@ -1,13 +1,28 @@
 package twitchext
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
 	"os"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
+	"unicode/utf8"
 
 	"github.com/jackmcguire1/go-twitch-ext/internal/utils"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
+// stubChannelID the channel ID used by tests against stubbed responses
+const stubChannelID = "123"
+
 var (
 	twitchPkg *Twitch
 
@@ -16,8 +31,24 @@ var (
 )
 
 type (
-	UtilTests struct{ Test *testing.T }
-	JWTTests  struct{ Test *testing.T }
+	UtilTests                 struct{ Test *testing.T }
+	JWTTests                  struct{ Test *testing.T }
+	ConfigurationUpdateTests  struct{ Test *testing.T }
+	ConfigurationDiffTests    struct{ Test *testing.T }
+	ConfigurationArchiveTests struct{ Test *testing.T }
+	OnboardingTests           struct{ Test *testing.T }
+	ChannelConfigurationTests struct{ Test *testing.T }
+	PublishTests              struct{ Test *testing.T }
+	PublishChunkTests         struct{ Test *testing.T }
+	ChannelPublisherTests     struct{ Test *testing.T }
+	FanOutTests               struct{ Test *testing.T }
+	BulkWhisperTests          struct{ Test *testing.T }
+	EventTests                struct{ Test *testing.T }
+	SchedulerTests            struct{ Test *testing.T }
+	IdempotencyTests          struct{ Test *testing.T }
+	PermissionTests           struct{ Test *testing.T }
+	ChatTests                 struct{ Test *testing.T }
+	LiveChannelTests          struct{ Test *testing.T }
 
 	//TODO test without Twitch production API
 	//ConfigurationTests      struct{ Test *testing.T }
@@ -28,6 +59,40 @@ type (
 	//TestObj struct{ Name string }
 )
 
+// roundTripFunc allows tests to stub the Twitch API
+// by supplying a custom http.Client transport.
+type roundTripFunc func(req *http.Request) *http.Response
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req), nil
+}
+
+func newStubClient(f roundTripFunc, opts ...*Options) *Twitch {
+	options := &Options{}
+	if len(opts) > 0 {
+		options = opts[0]
+	}
+	options.Client = &http.Client{Transport: f}
+
+	return NewClient(
+		twitchPkg.OwnerID,
+		twitchPkg.ClientID,
+		twitchPkg.Secret,
+		twitchPkg.Version,
+		twitchPkg.ConfigVersion,
+		options,
+	)
+}
+
+func stubResponse(code int, body string) *http.Response {
+	return &http.Response{
+		StatusCode: code,
+		Status:     http.StatusText(code),
+		Header:     http.Header{},
+		Body:       ioutil.NopCloser(strings.NewReader(body)),
+	}
+}
+
 func init() {
 	twitchPkg = NewClient(
 		os.Getenv("OWNER_ID"),
@@ -57,6 +122,129 @@ func TestRunner(t *testing.T) {
 		test.TestJWTVerify()
 	})
 
+	t.Run("A=configuration-update", func(t *testing.T) {
+		test := ConfigurationUpdateTests{Test: t}
+		test.TestUpdateSegment()
+		test.TestUpdateSegmentConflict()
+	})
+
+	t.Run("A=configuration-diff", func(t *testing.T) {
+		test := ConfigurationDiffTests{Test: t}
+		test.TestDiffJSON()
+		test.TestSetSegmentNotifyChange()
+	})
+
+	t.Run("A=configuration-archive", func(t *testing.T) {
+		test := ConfigurationArchiveTests{Test: t}
+		test.TestExportConfigurations()
+		test.TestImportConfigurations()
+	})
+
+	t.Run("A=onboarding", func(t *testing.T) {
+		test := OnboardingTests{Test: t}
+		test.TestCompleteOnboarding()
+	})
+
+	t.Run("A=channel-configuration", func(t *testing.T) {
+		test := ChannelConfigurationTests{Test: t}
+		test.TestGetAllChannelConfigurations()
+	})
+
+	t.Run("A=publish", func(t *testing.T) {
+		test := PublishTests{Test: t}
+		test.TestPublish()
+		test.TestPublishValidation()
+	})
+
+	t.Run("A=publish-chunk", func(t *testing.T) {
+		test := PublishChunkTests{Test: t}
+		test.TestPublishMessageTooLarge()
+		test.TestPublishChunked()
+	})
+
+	t.Run("A=channel-publisher", func(t *testing.T) {
+		test := ChannelPublisherTests{Test: t}
+		test.TestChannelPublisher()
+	})
+
+	t.Run("A=fan-out", func(t *testing.T) {
+		test := FanOutTests{Test: t}
+		test.TestPublishToChannels()
+	})
+
+	t.Run("A=bulk-whisper", func(t *testing.T) {
+		test := BulkWhisperTests{Test: t}
+		test.TestPublishWhispers()
+	})
+
+	t.Run("A=event", func(t *testing.T) {
+		test := EventTests{Test: t}
+		test.TestEventRegistry()
+	})
+
+	t.Run("A=scheduler", func(t *testing.T) {
+		test := SchedulerTests{Test: t}
+		test.TestScheduler()
+		test.TestSchedulerRetry()
+	})
+
+	t.Run("A=idempotency", func(t *testing.T) {
+		test := IdempotencyTests{Test: t}
+		test.TestPublishIdempotencyKey()
+	})
+
+	t.Run("A=permissions", func(t *testing.T) {
+		test := PermissionTests{Test: t}
+		test.TestFormListenPubSubPermissions()
+		test.TestNewPubSubPermissions()
+	})
+
+	t.Run("A=chat", func(t *testing.T) {
+		test := ChatTests{Test: t}
+		test.TestSendTwitchChatMessageCountsCharacters()
+		test.TestSendTwitchChatMessageAPIs()
+		test.TestSplitChatMessage()
+		test.TestSendTwitchChatMessages()
+	})
+
+	t.Run("A=chat-dispatcher", func(t *testing.T) {
+		test := ChatTests{Test: t}
+		test.TestChatDispatcherPriority()
+		test.TestChatDispatcherRateLimited()
+		test.TestChatDispatcherStale()
+	})
+
+	t.Run("A=chat-template", func(t *testing.T) {
+		test := ChatTests{Test: t}
+		test.TestChatCatalogRender()
+		test.TestSendTemplatedChatMessage()
+	})
+
+	t.Run("A=chat-filter", func(t *testing.T) {
+		test := ChatTests{Test: t}
+		test.TestFilterChatMessage()
+		test.TestSendTwitchChatMessageFiltered()
+	})
+
+	t.Run("A=live-channels", func(t *testing.T) {
+		test := LiveChannelTests{Test: t}
+		test.TestGetLiveChannelsWithExtensionEnabled()
+		test.TestGetLiveChannelsAuth()
+		test.TestEachLiveChannel()
+		test.TestCollectLiveChannels()
+	})
+
+	t.Run("A=channel-watcher", func(t *testing.T) {
+		test := LiveChannelTests{Test: t}
+		test.TestChannelWatcher()
+	})
+
+	t.Run("A=live-aggregator", func(t *testing.T) {
+		test := LiveChannelTests{Test: t}
+		test.TestLiveAggregator()
+		test.TestSnapshotLiveChannels()
+	})
+
 	//TODO test without Twitch production API
 	//t.Run("A=messaging", func(t *testing.T) {
 	//		test := MessagingAndPubSubTests{Test: t}
@@ -174,6 +362,1723 @@ func (t *JWTTests) TestJWTVerify() {
 	assert.EqualValues(claims.Role, ExternalRole)
 }
 
+// segmentStore stubs the configuration segment endpoints
+// for a single broadcaster segment.
+type segmentStore struct {
+	record *Record
+	// onRead is invoked before every segment read
+	onRead func(reads int)
+	reads  int
+}
+
+func (s *segmentStore) roundTrip(req *http.Request) *http.Response {
+	switch req.Method {
+	case http.MethodGet:
+		s.reads++
+		if s.onRead != nil {
+			s.onRead(s.reads)
+		}
+		if s.record == nil {
+			return stubResponse(http.StatusOK, "{}")
+		}
+		requested := req.URL.Query().Get("channel_id")
+		key := string(BroadcasterSegment) + ":" + requested
+		return stubResponse(http.StatusOK, utils.ToJSON(map[string]*Configuration{
+			key: {
+				Segment: &Segment{Segment: string(BroadcasterSegment), ChannelID: requested},
+				Record:  s.record,
+			},
+		}))
+	case http.MethodPut:
+		var params configurationParams
+		json.NewDecoder(req.Body).Decode(&params)
+		s.record = &Record{Version: params.Version, Content: params.Content}
+		return stubResponse(http.StatusNoContent, "")
+	}
+
+	return stubResponse(http.StatusMethodNotAllowed, "")
+}
+
+func (t *ConfigurationUpdateTests) TestUpdateSegment() {
+	assert := assert.New(t.Test)
+
+	store := &segmentStore{}
+	client := newStubClient(store.roundTrip)
+
+	_, err := client.UpdateSegment(BroadcasterSegment, stubChannelID, func(current *Record) (interface{}, error) {
+		assert.Nil(current)
+		return map[string]int{"count": 1}, nil
+	})
+	assert.NoError(err)
+	assert.EqualValues(`{"count":1}`, store.record.Content)
+
+	_, err = client.UpdateSegment(BroadcasterSegment, stubChannelID, func(current *Record) (interface{}, error) {
+		assert.NotNil(current)
+		var data map[string]int
+		assert.NoError(json.Unmarshal([]byte(current.Content), &data))
+		data["count"]++
+		return data, nil
+	})
+	assert.NoError(err)
+	assert.EqualValues(`{"count":2}`, store.record.Content)
+}
+
+func (t *ConfigurationUpdateTests) TestUpdateSegmentConflict() {
+	assert := assert.New(t.Test)
+
+	store := &segmentStore{record: &Record{Content: "{}"}}
+	store.onRead = func(reads int) {
+		// another writer modifies the segment between every read
+		store.record = &Record{Content: utils.ToJSON(map[string]int{"reads": reads})}
+	}
+	client := newStubClient(store.roundTrip)
+
+	mutations := 0
+	_, err := client.UpdateSegment(BroadcasterSegment, stubChannelID, func(current *Record) (interface{}, error) {
+		mutations++
+		return "ours", nil
+	}, &UpdateSegmentOptions{MaxAttempts: 2})
+	assert.ErrorIs(err, ErrConfigurationConflict)
+	assert.EqualValues(2, mutations)
+}
+
+func (t *ConfigurationDiffTests) TestDiffJSON() {
+	assert := assert.New(t.Test)
+
+	patch, err := DiffJSON(
+		`{"name":"a","list":[1,2],"nested":{"keep":true,"drop":1},"a/b":1}`,
+		`{"name":"b","list":[1,2],"nested":{"keep":true,"new":null}}`,
+	)
+	assert.NoError(err)
+	assert.EqualValues(
+		`[{"op":"remove","path":"/a~1b"},`+
+			`{"op":"replace","path":"/name","value":"b"},`+
+			`{"op":"remove","path":"/nested/drop"},`+
+			`{"op":"add","path":"/nested/new","value":null}]`,
+		utils.ToJSON(patch),
+	)
+
+	patch, err = DiffJSON("", `{"name":"a"}`)
+	assert.NoError(err)
+	assert.EqualValues(`[{"op":"replace","path":"","value":{"name":"a"}}]`, utils.ToJSON(patch))
+
+	patch, err = DiffJSON(`[1]`, `[1]`)
+	assert.NoError(err)
+	assert.Empty(patch)
+
+	_, err = DiffJSON(`{`, `{}`)
+	assert.Error(err)
+}
+
+func (t *ConfigurationDiffTests) TestSetSegmentNotifyChange() {
+	assert := assert.New(t.Test)
+
+	store := &segmentStore{record: &Record{Content: `{"name":"a"}`}}
+	var published []string
+	client := newStubClient(func(req *http.Request) *http.Response {
+		if strings.HasSuffix(req.URL.Path, "/extensions/pubsub") {
+			var notification helixPubSubNotification
+			json.NewDecoder(req.Body).Decode(&notification)
+			published = append(published, notification.Message)
+			return stubResponse(http.StatusNoContent, "")
+		}
+		return store.roundTrip(req)
+	})
+
+	_, err := client.SetBroadcasterSegment(map[string]string{"name": "b"}, stubChannelID, &SegmentOptions{NotifyChange: true})
+	require.NoError(t.Test, err)
+	require.Len(t.Test, published, 1)
+
+	var notification ConfigurationChangedNotification
+	assert.NoError(json.Unmarshal([]byte(published[0]), &notification))
+	assert.EqualValues(ConfigurationChangedEvent, notification.Type)
+	assert.EqualValues(BroadcasterSegment, notification.Segment)
+	assert.EqualValues(stubChannelID, notification.ChannelID)
+	assert.Len(notification.Patch, 1)
+
+	_, err = client.SetBroadcasterSegment(map[string]string{"name": "b"}, stubChannelID, &SegmentOptions{NotifyChange: true})
+	assert.NoError(err)
+	assert.Len(published, 1)
+}
+
+func (t *ConfigurationArchiveTests) TestExportConfigurations() {
+	assert := assert.New(t.Test)
+
+	client := newStubClient(func(req *http.Request) *http.Response {
+		switch {
+		case strings.HasSuffix(req.URL.Path, "/segments/global"):
+			return stubResponse(http.StatusOK, utils.ToJSON(map[string]*Configuration{
+				"global:": {Record: &Record{Version: "1", Content: `{"global":true}`}},
+			}))
+		case strings.HasSuffix(req.URL.Path, "/channels/1"):
+			return stubResponse(http.StatusOK, utils.ToJSON(map[string]*Configuration{
+				"broadcaster:1": {Record: &Record{Version: "2", Content: `{"channel":1}`}},
+			}))
+		case strings.HasSuffix(req.URL.Path, "/channels/2"):
+			return stubResponse(http.StatusOK, utils.ToJSON(map[string]*Configuration{
+				"broadcaster:2": {Record: &Record{Version: "2", Content: `{"channel":2}`}},
+				"developer:2":   {Record: &Record{Version: "3", Content: `{"dev":2}`}},
+			}))
+		}
+		return stubResponse(http.StatusNotFound, "")
+	})
+
+	archive := &strings.Builder{}
+	report, err := client.ExportConfigurations(archive, &ExportOptions{Channels: []string{"1", "2", "1"}})
+	assert.NoError(err)
+	assert.EqualValues(2, report.Channels)
+	assert.EqualValues(4, report.Entries)
+	assert.EqualValues(
+		`{"segment":"global","version":"1","content":"{\"global\":true}"}`+"\n"+
+			`{"segment":"broadcaster","channel_id":"1","version":"2","content":"{\"channel\":1}"}`+"\n"+
+			`{"segment":"broadcaster","channel_id":"2","version":"2","content":"{\"channel\":2}"}`+"\n"+
+			`{"segment":"developer","channel_id":"2","version":"3","content":"{\"dev\":2}"}`+"\n",
+		archive.String(),
+	)
+}
+
+func (t *ConfigurationArchiveTests) TestImportConfigurations() {
+	assert := assert.New(t.Test)
+
+	archive := `{"segment":"global","version":"1","content":"{}"}` + "\n\n" +
+		`{"segment":"broadcaster","channel_id":"1","version":"2","content":"{\"channel\":1}"}` + "\n"
+
+	var written []configurationParams
+	rateLimited := false
+	client := newStubClient(func(req *http.Request) *http.Response {
+		if !rateLimited {
+			rateLimited = true
+			return stubResponse(http.StatusTooManyRequests, "")
+		}
+		var params configurationParams
+		json.NewDecoder(req.Body).Decode(&params)
+		written = append(written, params)
+		return stubResponse(http.StatusNoContent, "")
+	})
+
+	report, err := client.ImportConfigurations(strings.NewReader(archive), &ImportOptions{DryRun: true})
+	assert.NoError(err)
+	assert.EqualValues(2, report.Entries)
+	assert.EqualValues(0, report.Written)
+	assert.Empty(written)
+
+	report, err = client.ImportConfigurations(strings.NewReader(archive), &ImportOptions{RateLimitWait: time.Millisecond})
+	assert.NoError(err)
+	assert.EqualValues(2, report.Written)
+	assert.EqualValues(1, report.RateLimited)
+	assert.Len(written, 2)
+	assert.EqualValues("2", written[1].Version)
+	assert.EqualValues(`{"channel":1}`, written[1].Content)
+	assert.EqualValues("1", written[1].ChannelID)
+
+	_, err = client.ImportConfigurations(strings.NewReader(`{"segment":"broadcaster"}`))
+	assert.Error(err)
+}
+
+func (t *OnboardingTests) TestCompleteOnboarding() {
+	assert := assert.New(t.Test)
+
+	store := &segmentStore{}
+	required := 0
+	client := newStubClient(func(req *http.Request) *http.Response {
+		if strings.HasSuffix(req.URL.Path, "/required_configuration") {
+			required++
+			return stubResponse(http.StatusNoContent, "")
+		}
+		return store.roundTrip(req)
+	})
+	client.ConfigVersion = "2"
+
+	opts := &OnboardingOptions{
+		Validator: func(record *Record) error {
+			if record.Content == "{}" {
+				return fmt.Errorf("empty configuration")
+			}
+			return nil
+		},
+	}
+
+	var onboardingErr *OnboardingError
+	result, _, err := client.CompleteOnboarding(stubChannelID, opts)
+	assert.ErrorAs(err, &onboardingErr)
+	require.NotNil(t.Test, result)
+	assert.EqualValues(OnboardingUnconfigured, result.Status)
+
+	store.record = &Record{Version: "1", Content: `{"name":"a"}`}
+	result, _, err = client.CompleteOnboarding(stubChannelID, opts)
+	assert.ErrorAs(err, &onboardingErr)
+	require.NotNil(t.Test, result)
+	assert.EqualValues(OnboardingOutdated, result.Status)
+
+	store.record = &Record{Version: "2", Content: "{}"}
+	result, _, err = client.CompleteOnboarding(stubChannelID, opts)
+	assert.ErrorAs(err, &onboardingErr)
+	require.NotNil(t.Test, result)
+	assert.EqualValues(OnboardingInvalid, result.Status)
+	assert.EqualError(result.ValidationError, "empty configuration")
+	assert.EqualValues(0, required)
+
+	store.record = &Record{Version: "2", Content: `{"name":"a"}`}
+	result, _, err = client.CompleteOnboarding(stubChannelID, opts)
+	require.NoError(t.Test, err)
+	require.NotNil(t.Test, result)
+	assert.EqualValues(OnboardingConfigured, result.Status)
+	assert.EqualValues(1, required)
+}
+
+func (t *ChannelConfigurationTests) TestGetAllChannelConfigurations() {
+	assert := assert.New(t.Test)
+
+	client := newStubClient(func(req *http.Request) *http.Response {
+		return stubResponse(http.StatusOK, utils.ToJSON(map[string]*Configuration{
+			"broadcaster:" + stubChannelID: {Record: &Record{Version: "1", Content: `{"name":"a"}`}},
+		}))
+	})
+
+	configs, err := client.GetAllChannelConfigurations(stubChannelID)
+	require.NoError(t.Test, err)
+	assert.NotNil(configs.Broadcaster)
+	assert.Nil(configs.Developer)
+	assert.Nil(configs.Global)
+	assert.Equal(configs.Broadcaster, configs.Configurations["broadcaster"])
+	assert.Equal(configs.Broadcaster, configs.Raw["broadcaster:"+stubChannelID])
+
+	var data struct{ Name string }
+	assert.NoError(configs.Decode(BroadcasterSegment, &data))
+	assert.EqualValues("a", data.Name)
+	assert.Error(configs.Decode(DeveloperSegment, &data))
+
+	var record *Record
+	assert.Error(record.Decode(&data))
+}
+
+func (t *PublishTests) TestPublish() {
+	assert := assert.New(t.Test)
+
+	var (
+		path   string
+		claims *TwitchJWTClaims
+		body   []byte
+	)
+	stub := func(req *http.Request) *http.Response {
+		path = req.URL.Path
+		claims, _ = twitchPkg.JWTVerify(strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer "))
+		body, _ = ioutil.ReadAll(req.Body)
+		return stubResponse(http.StatusNoContent, "")
+	}
+	client := newStubClient(stub)
+
+	msg := NewPubSubMessage(stubChannelID).
+		Broadcast().
+		Whisper("a", "b", "a").
+		Raw("hello")
+
+	var notification helixPubSubNotification
+	_, err := client.Publish(msg)
+	assert.NoError(err)
+	assert.NoError(json.Unmarshal(body, &notification))
+	assert.EqualValues("/helix/extensions/pubsub", path)
+	assert.EqualValues("hello", notification.Message)
+	assert.EqualValues(stubChannelID, notification.BroadcasterID)
+	assert.False(notification.IsGlobalBroadcast)
+	assert.EqualValues([]PublishType{BroadcastPublish, "whisper-a", "whisper-b"}, notification.Target)
+	assert.EqualValues(notification.Target, claims.Permissions.Send)
+	assert.EqualValues(stubChannelID, claims.ChannelID)
+
+	notification = helixPubSubNotification{}
+	_, err = client.PublishGlobalNotification(map[string]string{"a": "b"})
+	assert.NoError(err)
+	assert.NoError(json.Unmarshal(body, &notification))
+	assert.EqualValues(`{"a":"b"}`, notification.Message)
+	assert.Empty(notification.BroadcasterID)
+	assert.True(notification.IsGlobalBroadcast)
+	assert.EqualValues([]PublishType{GlobalPublish}, claims.Permissions.Send)
+	assert.EqualValues(toAllChannels, claims.ChannelID)
+
+	var unsupported *UnsupportedContentTypeError
+	body = nil
+	_, err = client.Publish(NewPubSubMessage(stubChannelID).Broadcast().ContentType("text/plain").Raw("hello"))
+	assert.ErrorAs(err, &unsupported)
+	assert.EqualValues("text/plain", unsupported.ContentType)
+	assert.Nil(body)
+
+	// the chunk marker carries the content type
+	notification = helixPubSubNotification{}
+	_, err = client.Publish(NewPubSubMessage(stubChannelID).Broadcast().ContentType("text/plain").Raw("hello").Chunked())
+	assert.NoError(err)
+	assert.NoError(json.Unmarshal(body, &notification))
+	reassembled, err := NewPubSubReassembler().Add([]byte(notification.Message))
+	assert.NoError(err)
+	assert.EqualValues("text/plain", reassembled.ContentType)
+	assert.EqualValues("hello", string(reassembled.Message))
+
+	legacy := newStubClient(stub, &Options{PubSubAPI: LegacyAPI})
+
+	var legacyNotification pubSubNotification
+	_, err = legacy.Publish(NewPubSubMessage(stubChannelID).Broadcast().ContentType("text/plain").Raw("hello"))
+	assert.NoError(err)
+	assert.NoError(json.Unmarshal(body, &legacyNotification))
+	assert.EqualValues("/extensions/message/"+stubChannelID, path)
+	assert.EqualValues("text/plain", legacyNotification.ContentType)
+	assert.EqualValues([]PublishType{BroadcastPublish}, legacyNotification.Targets)
+
+	_, err = legacy.PublishGlobalNotification("hello")
+	assert.NoError(err)
+	assert.EqualValues("/extensions/message/all", path)
+}
+
+func (t *PublishTests) TestPublishValidation() {
+	assert := assert.New(t.Test)
+
+	_, err := twitchPkg.Publish(NewPubSubMessage(stubChannelID).Raw("hello"))
+	assert.Error(err)
+
+	_, err = twitchPkg.Publish(NewPubSubMessage(stubChannelID).Global().Broadcast().Raw("hello"))
+	assert.Error(err)
+
+	_, err = twitchPkg.Publish(NewPubSubMessage("").Broadcast().Raw("hello"))
+	assert.Error(err)
+
+	_, err = twitchPkg.Publish(NewPubSubMessage(stubChannelID).Broadcast().JSON(make(chan int)))
+	assert.Error(err)
+}
+
+func (t *PublishChunkTests) TestPublishMessageTooLarge() {
+	assert := assert.New(t.Test)
+
+	var tooLarge *MessageTooLargeError
+	_, err := twitchPkg.Publish(NewPubSubMessage(stubChannelID).Broadcast().Raw(strings.Repeat("a", maxPubSubMessageSize+1)))
+	assert.ErrorAs(err, &tooLarge)
+	assert.EqualValues(maxPubSubMessageSize+1, tooLarge.Size)
+}
+
+func (t *PublishChunkTests) TestPublishChunked() {
+	assert := assert.New(t.Test)
+
+	var messages []string
+	client := newStubClient(func(req *http.Request) *http.Response {
+		var notification helixPubSubNotification
+		json.NewDecoder(req.Body).Decode(&notification)
+		messages = append(messages, notification.Message)
+		return stubResponse(http.StatusNoContent, "")
+	})
+
+	payload := strings.Repeat(`{"emoji":"🎉<>&"}`, 1000)
+	_, err := client.Publish(NewPubSubMessage(stubChannelID).Broadcast().ContentType("text/plain").Raw(payload).Chunked())
+	assert.NoError(err)
+	assert.True(len(messages) > 1)
+
+	reassembler := NewPubSubReassembler()
+	for i := len(messages) - 1; i >= 0; i-- {
+		assert.True(len(messages[i]) <= maxPubSubMessageSize)
+
+		msg, err := reassembler.Add([]byte(messages[i]))
+		assert.NoError(err)
+		if i > 0 {
+			assert.Nil(msg)
+			continue
+		}
+		assert.EqualValues(payload, string(msg.Message))
+		assert.EqualValues("text/plain", msg.ContentType)
+	}
+	assert.EqualValues(0, reassembler.Pending())
+
+	msg, err := reassembler.Add([]byte(`{"hello":"world"}`))
+	assert.NoError(err)
+	assert.EqualValues(`{"hello":"world"}`, string(msg.Message))
+}
+
+func (t *ChannelPublisherTests) TestChannelPublisher() {
+	assert := assert.New(t.Test)
+
+	var (
+		mu       sync.Mutex
+		messages []string
+		sent     []time.Time
+	)
+	started := make(chan bool, 1)
+	release := make(chan bool)
+	client := newStubClient(func(req *http.Request) *http.Response {
+		var notification helixPubSubNotification
+		json.NewDecoder(req.Body).Decode(&notification)
+
+		mu.Lock()
+		messages = append(messages, notification.Message)
+		sent = append(sent, time.Now())
+		first := len(messages) == 1
+		mu.Unlock()
+
+		if first {
+			started <- true
+			<-release
+		}
+		return stubResponse(http.StatusNoContent, "")
+	})
+
+	interval := 20 * time.Millisecond
+	publisher := client.NewChannelPublisher(&ChannelPublisherOptions{Interval: interval, MaxQueue: 3})
+
+	assert.NoError(publisher.Publish(stubChannelID, "state", 1))
+	<-started
+
+	assert.NoError(publisher.Publish(stubChannelID, "state", 2))
+	assert.NoError(publisher.Publish(stubChannelID, "state", 3))
+	assert.NoError(publisher.Publish(stubChannelID, "", "x"))
+	assert.NoError(publisher.Publish(stubChannelID, "", "y"))
+	assert.NoError(publisher.Publish(stubChannelID, "", "z"))
+	assert.EqualValues(3, publisher.Pending(stubChannelID))
+	close(release)
+
+	publisher.Close()
+	assert.Error(publisher.Publish(stubChannelID, "", "closed"))
+
+	assert.EqualValues([]string{"1", `"x"`, `"y"`, `"z"`}, messages)
+	for i := 1; i < len(sent); i++ {
+		// allow for scheduling jitter between the stub and the publisher
+		assert.True(sent[i].Sub(sent[i-1]) >= interval/2)
+	}
+	assert.EqualValues(ChannelPublisherStats{Published: 4, Coalesced: 1, Dropped: 1}, publisher.Stats())
+}
+
+func (t *FanOutTests) TestPublishToChannels() {
+	assert := assert.New(t.Test)
+
+	var (
+		mu       sync.Mutex
+		attempts = map[string]int{}
+	)
+	client := newStubClient(func(req *http.Request) *http.Response {
+		var notification helixPubSubNotification
+		json.NewDecoder(req.Body).Decode(&notification)
+		channel := notification.BroadcasterID
+
+		mu.Lock()
+		attempts[channel]++
+		attempt := attempts[channel]
+		mu.Unlock()
+
+		switch {
+		case channel == "failed":
+			return stubResponse(http.StatusBadRequest, "")
+		case channel == "limited":
+			return stubResponse(http.StatusTooManyRequests, "")
+		case channel == "retried" && attempt == 1:
+			return stubResponse(http.StatusTooManyRequests, "")
+		}
+		return stubResponse(http.StatusNoContent, "")
+	})
+
+	report, err := client.PublishToChannels(
+		[]string{"1", "2", "1", "failed", "limited", "retried"},
+		map[string]string{"announcement": "hello"},
+		&FanOutOptions{Concurrency: 2, RetryWait: time.Millisecond},
+	)
+	assert.NoError(err)
+	assert.Len(report.Results, 5)
+	assert.EqualValues(3, report.Succeeded)
+	assert.EqualValues(1, report.RateLimited)
+	assert.EqualValues(1, report.Failed)
+
+	var rateLimited *RateLimitError
+	assert.EqualValues("limited", report.Results[3].ChannelID)
+	assert.EqualValues(FanOutRateLimited, report.Results[3].Status)
+	assert.ErrorAs(report.Results[3].Err, &rateLimited)
+	assert.EqualValues(2, report.Results[3].Attempts)
+	assert.EqualValues(FanOutSuccess, report.Results[4].Status)
+	assert.EqualValues(2, report.Results[4].Attempts)
+	assert.EqualValues(1, attempts["1"])
+
+	report, err = client.PublishToChannels(
+		[]string{"limited", "1"},
+		map[string]string{"announcement": "hello"},
+		&FanOutOptions{Concurrency: -1, RateLimitRetries: -1, RetryWait: -1},
+	)
+	assert.NoError(err)
+	assert.EqualValues(1, report.RateLimited)
+	assert.EqualValues(1, report.Succeeded)
+	assert.EqualValues(1, report.Results[0].Attempts)
+
+	_, err = client.PublishToChannels([]string{"1"}, make(chan int))
+	assert.Error(err)
+}
+
+func (t *BulkWhisperTests) TestPublishWhispers() {
+	assert := assert.New(t.Test)
+
+	var (
+		mu            sync.Mutex
+		notifications []helixPubSubNotification
+		sent          []time.Time
+	)
+	client := newStubClient(func(req *http.Request) *http.Response {
+		var notification helixPubSubNotification
+		json.NewDecoder(req.Body).Decode(&notification)
+
+		mu.Lock()
+		notifications = append(notifications, notification)
+		sent = append(sent, time.Now())
+		mu.Unlock()
+
+		if notification.Message == `"lose"` {
+			return stubResponse(http.StatusBadRequest, "")
+		}
+		return stubResponse(http.StatusNoContent, "")
+	})
+
+	interval := 20 * time.Millisecond
+	report, err := client.PublishWhispers(stubChannelID, []*Whisper{
+		{OpaqueUserID: "a", Data: "win"},
+		{OpaqueUserID: "b", Data: "win"},
+		{OpaqueUserID: "c", Data: "lose"},
+		{OpaqueUserID: "d", Data: "win"},
+	}, &BulkWhisperOptions{TargetsPerMessage: 2, Interval: interval})
+	assert.NoError(err)
+	assert.EqualValues(3, report.Messages)
+	require.Len(t.Test, notifications, 3)
+	// messages to the channel are paced rather than sent at once
+	for i := 1; i < len(sent); i++ {
+		assert.True(sent[i].Sub(sent[i-1]) >= interval*9/10)
+	}
+	assert.EqualValues(3, report.Succeeded)
+	assert.EqualValues(1, report.Failed)
+	assert.EqualValues("c", report.Results[2].OpaqueUserID)
+	assert.EqualValues(FanOutFailed, report.Results[2].Status)
+	assert.Error(report.Results[2].Err)
+
+	targets := map[string][]PublishType{}
+	for _, notification := range notifications {
+		targets[notification.Message] = append(targets[notification.Message], notification.Target...)
+	}
+	assert.ElementsMatch([]PublishType{"whisper-a", "whisper-b", "whisper-d"}, targets[`"win"`])
+	assert.ElementsMatch([]PublishType{"whisper-c"}, targets[`"lose"`])
+
+	report, err = client.PublishWhispers(stubChannelID, []*Whisper{
+		{OpaqueUserID: "a", Data: "win"},
+		{OpaqueUserID: "a", Data: "win"},
+	})
+	assert.NoError(err)
+	assert.Len(report.Results, 2)
+	assert.EqualValues(1, report.Messages)
+	assert.EqualValues(1, report.Succeeded)
+
+	_, err = client.PublishWhispers(stubChannelID, []*Whisper{{Data: "win"}})
+	assert.Error(err)
+}
+
+type pollStarted struct {
+	Question string `json:"question"`
+}
+
+type pollStartedV2 struct {
+	Question string   `json:"question"`
+	Options  []string `json:"options"`
+}
+
+func (t *EventTests) TestEventRegistry() {
+	assert := assert.New(t.Test)
+
+	registry := NewEventRegistry("ebs-1")
+	assert.NoError(registry.Register("poll_started", 1, pollStarted{}))
+	assert.NoError(registry.Register("poll_started", 2, &pollStartedV2{}))
+	assert.Error(registry.Register("poll_started", 3, pollStarted{}))
+	assert.Error(registry.Register("poll_started", 1, struct{}{}))
+
+	var message string
+	client := newStubClient(func(req *http.Request) *http.Response {
+		var notification helixPubSubNotification
+		json.NewDecoder(req.Body).Decode(&notification)
+		message = notification.Message
+		return stubResponse(http.StatusNoContent, "")
+	})
+
+	_, err := client.PublishChannelEvent(registry, stubChannelID, &pollStartedV2{Question: "?", Options: []string{"a"}})
+	assert.NoError(err)
+
+	var envelope Envelope
+	assert.NoError(json.Unmarshal([]byte(message), &envelope))
+	assert.EqualValues("poll_started", envelope.Event)
+	assert.EqualValues(2, envelope.Version)
+	assert.EqualValues("ebs-1", envelope.Sender)
+	assert.NotEmpty(envelope.MessageID)
+
+	_, err = client.PublishChannelEvent(registry, stubChannelID, "unregistered")
+	assert.Error(err)
+
+	var received []interface{}
+	assert.NoError(registry.Handle(func(env *Envelope, poll pollStarted) error {
+		received = append(received, poll)
+		return nil
+	}))
+	assert.NoError(registry.Handle(func(env *Envelope, poll *pollStartedV2) error {
+		received = append(received, poll)
+		return nil
+	}))
+	assert.Error(registry.Handle(func(poll pollStarted) {}))
+
+	assert.NoError(registry.Dispatch([]byte(message)))
+	v1, _ := registry.Wrap(pollStarted{Question: "v1"})
+	assert.NoError(registry.Dispatch(utils.ToRawMessage(v1)))
+	assert.EqualValues([]interface{}{
+		&pollStartedV2{Question: "?", Options: []string{"a"}},
+		pollStarted{Question: "v1"},
+	}, received)
+
+	var unknown *UnknownEventError
+	assert.ErrorAs(registry.Dispatch([]byte(`{"event":"poll_started","version":9}`)), &unknown)
+}
+
+func (t *SchedulerTests) TestScheduler() {
+	assert := assert.New(t.Test)
+
+	published := make(chan string, 10)
+	client := newStubClient(func(req *http.Request) *http.Response {
+		var notification helixPubSubNotification
+		json.NewDecoder(req.Body).Decode(&notification)
+		published <- notification.Message
+		return stubResponse(http.StatusNoContent, "")
+	})
+
+	store := NewMemoryScheduleStore()
+	store.Save(&ScheduledJob{ID: "restored", ChannelID: stubChannelID, Message: []byte(`"restored"`)})
+
+	scheduler, err := client.NewScheduler(&SchedulerOptions{Store: store})
+	assert.NoError(err)
+	assert.EqualValues(`"restored"`, <-published)
+
+	now := time.Now()
+	later, err := scheduler.ScheduleChannel(stubChannelID, now.Add(time.Hour), "later")
+	assert.NoError(err)
+	cancelled, err := scheduler.ScheduleGlobal(now.Add(time.Hour), "cancelled")
+	assert.NoError(err)
+	_, err = scheduler.ScheduleChannel(stubChannelID, now.Add(30*time.Millisecond), "soon")
+	assert.NoError(err)
+
+	assert.NoError(scheduler.Cancel(cancelled))
+	assert.Error(scheduler.Cancel(cancelled))
+	assert.NoError(scheduler.Reschedule(later, now.Add(10*time.Millisecond)))
+
+	assert.EqualValues(`"later"`, <-published)
+	assert.EqualValues(`"soon"`, <-published)
+	assert.Empty(scheduler.Jobs())
+
+	pending, err := scheduler.ScheduleChannel(stubChannelID, now.Add(time.Hour), "pending")
+	assert.NoError(err)
+	scheduler.Close()
+	scheduler.Close()
+
+	_, err = scheduler.ScheduleGlobal(now, "closed")
+	assert.Error(err)
+	assert.Error(scheduler.Reschedule(pending, now))
+
+	jobs, _ := store.List()
+	assert.Len(jobs, 1)
+	assert.EqualValues(`"pending"`, jobs[0].Message)
+}
+
+func (t *SchedulerTests) TestSchedulerRetry() {
+	assert := assert.New(t.Test)
+
+	var (
+		mu        sync.Mutex
+		published []string
+	)
+	client := newStubClient(func(req *http.Request) *http.Response {
+		var notification helixPubSubNotification
+		json.NewDecoder(req.Body).Decode(&notification)
+
+		mu.Lock()
+		defer mu.Unlock()
+		published = append(published, notification.Message)
+
+		switch {
+		case notification.Message == `"failing"`:
+			return stubResponse(http.StatusInternalServerError, "")
+		case len(published) == 1:
+			return stubResponse(http.StatusTooManyRequests, "")
+		}
+		return stubResponse(http.StatusNoContent, "")
+	})
+
+	failed := make(chan *ScheduledJob, 1)
+	store := NewMemoryScheduleStore()
+	scheduler, err := client.NewScheduler(&SchedulerOptions{
+		Store:      store,
+		MaxRetries: 1,
+		RetryWait:  time.Millisecond,
+		OnError: func(job *ScheduledJob, err error) {
+			failed <- job
+		},
+	})
+	require.NoError(t.Test, err)
+	defer scheduler.Close()
+
+	// a rate limited job is kept and published once retried
+	_, err = scheduler.ScheduleChannel(stubChannelID, time.Now(), "reveal")
+	assert.NoError(err)
+	assert.Eventually(func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(published) == 2
+	}, time.Second, time.Millisecond)
+
+	// a failing job is removed once its retries are exhausted
+	_, err = scheduler.ScheduleChannel(stubChannelID, time.Now(), "failing")
+	assert.NoError(err)
+	select {
+	case job := <-failed:
+		assert.EqualValues(1, job.Attempts)
+	case <-time.After(time.Second):
+		t.Test.Fatal("timed out waiting for the failed job")
+	}
+
+	mu.Lock()
+	assert.EqualValues([]string{`"reveal"`, `"reveal"`, `"failing"`, `"failing"`}, published)
+	mu.Unlock()
+	jobs, _ := store.List()
+	assert.Empty(jobs)
+}
+
+func (t *IdempotencyTests) TestPublishIdempotencyKey() {
+	assert := assert.New(t.Test)
+
+	var messages []string
+	fail := true
+	client := newStubClient(func(req *http.Request) *http.Response {
+		var notification helixPubSubNotification
+		json.NewDecoder(req.Body).Decode(&notification)
+		messages = append(messages, notification.Message)
+		if fail {
+			return stubResponse(http.StatusInternalServerError, "")
+		}
+		return stubResponse(http.StatusNoContent, "")
+	})
+
+	publish := func() error {
+		_, err := client.Publish(NewPubSubMessage(stubChannelID).Broadcast().Raw(`{"round":1}`).IdempotencyKey("round-1"))
+		return err
+	}
+
+	// failed publishes release the key so they can be retried
+	assert.Error(publish())
+	fail = false
+	assert.NoError(publish())
+	assert.ErrorIs(publish(), ErrDuplicatePublish)
+	assert.Len(messages, 2)
+
+	msg, ok := UnwrapIdempotentMessage([]byte(messages[1]))
+	assert.True(ok)
+	assert.EqualValues("round-1", msg.IdempotencyKey)
+	assert.EqualValues(defaultPubSubContentType, msg.ContentType)
+	assert.EqualValues(`{"round":1}`, msg.Message)
+
+	_, ok = UnwrapIdempotentMessage([]byte(`{"round":1}`))
+	assert.False(ok)
+
+	viewer := NewIdempotencyCache(time.Minute)
+	assert.False(viewer.Seen(msg.IdempotencyKey))
+	assert.True(viewer.Seen(msg.IdempotencyKey))
+}
+
+func (t *PermissionTests) TestFormListenPubSubPermissions() {
+	assert := assert.New(t.Test)
+
+	permissions := FormBroadcastListenPubSubPermissions()
+	assert.Empty(permissions.Send)
+	assert.EqualValues([]PublishType{BroadcastPublish}, permissions.Listen)
+
+	permissions = FormGlobalListenPubSubPermissions()
+	assert.EqualValues([]PublishType{GlobalPublish}, permissions.Listen)
+
+	permissions = FormWhisperListenPubSubPermissions("UnywsWXUjrEcUMVzt_qhB")
+	assert.EqualValues([]PublishType{"whisper-UnywsWXUjrEcUMVzt_qhB"}, permissions.Listen)
+
+	permissions = FormBroadcastSendListenPubSubPermissions()
+	assert.EqualValues([]PublishType{BroadcastPublish}, permissions.Send)
+	assert.EqualValues([]PublishType{BroadcastPublish}, permissions.Listen)
+
+	permissions = FormWhisperSendListenPubSubPermissions("a")
+	assert.EqualValues([]PublishType{"whisper-a"}, permissions.Send)
+	assert.EqualValues([]PublishType{"whisper-a"}, permissions.Listen)
+
+	permissions = FormViewerListenPubSubPermissions("a")
+	assert.NoError(permissions.Validate())
+	assert.EqualValues([]PublishType{BroadcastPublish, GlobalPublish, "whisper-a"}, permissions.Listen)
+
+	claims := twitchPkg.CreateClaims(stubChannelID, ViewerRole, FormGlobalSendListenPubSubPermissions())
+	token, err := twitchPkg.JWTSign(claims)
+	assert.NoError(err)
+	claims, err = twitchPkg.JWTVerify(token)
+	assert.NoError(err)
+	assert.EqualValues([]PublishType{GlobalPublish}, claims.Permissions.Listen)
+}
+
+func (t *PermissionTests) TestNewPubSubPermissions() {
+	assert := assert.New(t.Test)
+
+	permissions, err := NewPubSubPermissions(
+		[]PublishType{BroadcastPublish},
+		[]PublishType{BroadcastPublish, WhisperTarget("a")},
+	)
+	assert.NoError(err)
+	assert.EqualValues([]PublishType{BroadcastPublish, "whisper-a"}, permissions.Listen)
+
+	_, err = NewPubSubPermissions(nil, nil)
+	assert.Error(err)
+
+	_, err = NewPubSubPermissions([]PublishType{"unknown"}, nil)
+	assert.Error(err)
+
+	_, err = NewPubSubPermissions(nil, []PublishType{WhisperTarget("")})
+	assert.Error(err)
+
+	_, err = NewPubSubPermissions([]PublishType{BroadcastPublish, BroadcastPublish}, nil)
+	assert.Error(err)
+
+	_, err = NewPubSubPermissions(nil, []PublishType{GenericPublish, GlobalPublish})
+	assert.Error(err)
+}
+
+func (t *ChatTests) TestSendTwitchChatMessageCountsCharacters() {
+	assert := assert.New(t.Test)
+
+	client := newStubClient(func(req *http.Request) *http.Response {
+		return stubResponse(http.StatusNoContent, "")
+	})
+
+	// 280 characters but far more than 280 bytes
+	_, err := client.SendTwitchChatMessage(stubChannelID, strings.Repeat("🎉", maxMessageSize))
+	assert.NoError(err)
+
+	_, err = client.SendTwitchChatMessage(stubChannelID, strings.Repeat("é", maxMessageSize+1))
+	assert.EqualError(err, fmt.Sprintf("message %q exceeds 280 character limit", strings.Repeat("é", maxMessageSize+1)))
+}
+
+func (t *ChatTests) TestSendTwitchChatMessageAPIs() {
+	assert := assert.New(t.Test)
+
+	var (
+		path   string
+		query  string
+		claims *TwitchJWTClaims
+		body   []byte
+	)
+	stub := func(req *http.Request) *http.Response {
+		path = req.URL.Path
+		query = req.URL.RawQuery
+		claims, _ = twitchPkg.JWTVerify(strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer "))
+		body, _ = ioutil.ReadAll(req.Body)
+		return stubResponse(http.StatusNoContent, "")
+	}
+
+	var msg helixChatMessage
+	_, err := newStubClient(stub).SendTwitchChatMessage(stubChannelID, "hello")
+	assert.NoError(err)
+	assert.NoError(json.Unmarshal(body, &msg))
+	assert.EqualValues("/helix/extensions/chat", path)
+	assert.EqualValues("broadcaster_id="+stubChannelID, query)
+	assert.EqualValues("hello", msg.Text)
+	assert.EqualValues(twitchPkg.ClientID, msg.ExtensionID)
+	assert.EqualValues(twitchPkg.Version, msg.ExtensionVersion)
+	assert.EqualValues(ExternalRole, claims.Role)
+	assert.EqualValues(twitchPkg.OwnerID, claims.UserID)
+
+	var legacyMsg chatMessage
+	_, err = newStubClient(stub, &Options{ChatAPI: LegacyAPI}).SendTwitchChatMessage(stubChannelID, "hello")
+	assert.NoError(err)
+	assert.NoError(json.Unmarshal(body, &legacyMsg))
+	assert.EqualValues(fmt.Sprintf("/extensions/%s/%s/channels/%s/chat", twitchPkg.ClientID, twitchPkg.Version, stubChannelID), path)
+	assert.Empty(query)
+	assert.EqualValues("hello", legacyMsg.Text)
+	assert.EqualValues(BroadcasterRole, claims.Role)
+}
+
+func (t *ChatTests) TestSplitChatMessage() {
+	assert := assert.New(t.Test)
+
+	parts, err := SplitChatMessage("short   message")
+	assert.NoError(err)
+	assert.EqualValues([]string{"short   message"}, parts)
+
+	text := strings.Repeat("ünïcödé ", 100) + strings.Repeat("x", 600)
+	parts, err = SplitChatMessage(text)
+	assert.NoError(err)
+	assert.True(len(parts) > 1)
+
+	rebuilt := ""
+	for i, part := range parts {
+		assert.True(utf8.RuneCountInString(part) <= maxMessageSize)
+		if i > 0 {
+			assert.True(strings.HasPrefix(part, "…"))
+			part = strings.TrimPrefix(part, "…")
+		}
+		if i < len(parts)-1 {
+			assert.True(strings.HasSuffix(part, "…"))
+			part = strings.TrimSuffix(part, "…")
+		}
+		rebuilt += part
+	}
+	assert.EqualValues(strings.ReplaceAll(text, " ", ""), strings.ReplaceAll(rebuilt, " ", ""))
+	assert.True(strings.HasSuffix(parts[0], "ünïcödé…"))
+
+	_, err = SplitChatMessage(text, &ChatSplitOptions{MaxParts: 2})
+	assert.Error(err)
+
+	parts, err = SplitChatMessage(strings.Repeat(" \n", maxMessageSize))
+	assert.Error(err)
+	assert.Nil(parts)
+}
+
+func (t *ChatTests) TestSendTwitchChatMessages() {
+	assert := assert.New(t.Test)
+
+	var sent []string
+	client := newStubClient(func(req *http.Request) *http.Response {
+		var msg chatMessage
+		json.NewDecoder(req.Body).Decode(&msg)
+		sent = append(sent, msg.Text)
+
+		res := stubResponse(http.StatusNoContent, "")
+		res.Header.Set("Ratelimit-Ratelimiterextensionchatmessages-Remaining", strconv.Itoa(2-len(sent)))
+		return res
+	})
+
+	responses, err := client.SendTwitchChatMessages(stubChannelID, strings.Repeat("word ", 100))
+	assert.NoError(err)
+	assert.Len(responses, 2)
+	assert.Len(sent, 2)
+
+	sent = nil
+	var rateLimited *RateLimitError
+	responses, err = client.SendTwitchChatMessages(stubChannelID, strings.Repeat("word ", 200))
+	assert.ErrorAs(err, &rateLimited)
+	assert.Len(responses, 2)
+
+	sent = nil
+	responses, err = client.SendTwitchChatMessages(stubChannelID, strings.Repeat(" ", maxMessageSize+1))
+	assert.Error(err)
+	assert.Empty(responses)
+	assert.Empty(sent)
+}
+
+// blockingChatClient stubs the chat endpoint, blocking
+// the first message sent until release is closed.
+func blockingChatClient(started chan bool, release chan bool) (*Twitch, func() ([]string, []time.Time)) {
+	var (
+		mu   sync.Mutex
+		sent []string
+		at   []time.Time
+	)
+
+	client := newStubClient(func(req *http.Request) *http.Response {
+		var msg chatMessage
+		json.NewDecoder(req.Body).Decode(&msg)
+
+		mu.Lock()
+		sent = append(sent, msg.Text)
+		at = append(at, time.Now())
+		first := len(sent) == 1
+		mu.Unlock()
+
+		if first {
+			started <- true
+			<-release
+		}
+		return stubResponse(http.StatusNoContent, "")
+	})
+
+	return client, func() ([]string, []time.Time) {
+		mu.Lock()
+		defer mu.Unlock()
+		return sent, at
+	}
+}
+
+func (t *ChatTests) TestChatDispatcherPriority() {
+	assert := assert.New(t.Test)
+
+	started := make(chan bool, 1)
+	release := make(chan bool)
+	client, sent := blockingChatClient(started, release)
+
+	results := make(chan *ChatResult, 10)
+	window := 100 * time.Millisecond
+	dispatcher := client.NewChatDispatcher(&ChatDispatcherOptions{
+		MessagesPerWindow: 2,
+		Window:            window,
+		Results:           results,
+	})
+
+	_, err := dispatcher.Send(stubChannelID, "first", ChatPriorityNormal)
+	assert.NoError(err)
+	<-started
+
+	dispatcher.Send(stubChannelID, "normal", ChatPriorityNormal)
+	dispatcher.Send(stubChannelID, "low", ChatPriorityLow)
+	dispatcher.Send(stubChannelID, "moderation", ChatPriorityHigh)
+	dispatcher.Send(stubChannelID, "normal-2", ChatPriorityNormal)
+	close(release)
+
+	dispatcher.Close()
+	close(results)
+
+	messages, at := sent()
+	assert.EqualValues([]string{"first", "moderation", "normal", "normal-2", "low"}, messages)
+	// no more than 2 messages are sent within a window
+	for i := 2; i < len(at); i++ {
+		assert.True(at[i].Sub(at[i-2]) >= window/2)
+	}
+
+	count := 0
+	for result := range results {
+		assert.EqualValues(ChatSent, result.Status)
+		count++
+	}
+	assert.EqualValues(5, count)
+}
+
+func (t *ChatTests) TestChatDispatcherRateLimited() {
+	assert := assert.New(t.Test)
+
+	var (
+		mu   sync.Mutex
+		sent []string
+		at   []time.Time
+	)
+	client := newStubClient(func(req *http.Request) *http.Response {
+		var msg chatMessage
+		json.NewDecoder(req.Body).Decode(&msg)
+
+		mu.Lock()
+		defer mu.Unlock()
+		sent = append(sent, msg.Text)
+		at = append(at, time.Now())
+		// the channel limit is reached outside of the dispatcher
+		if len(sent) == 2 {
+			return stubResponse(http.StatusTooManyRequests, "")
+		}
+		return stubResponse(http.StatusNoContent, "")
+	})
+
+	window := 100 * time.Millisecond
+	dispatcher := client.NewChatDispatcher(&ChatDispatcherOptions{
+		MessagesPerWindow: 2,
+		Window:            window,
+	})
+
+	dispatcher.Send(stubChannelID, "a", ChatPriorityNormal)
+	time.Sleep(window / 2)
+	dispatcher.Send(stubChannelID, "b", ChatPriorityNormal)
+	dispatcher.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Len(t.Test, at, 3)
+	assert.EqualValues([]string{"a", "b", "b"}, sent)
+	// the retry waits a full window after the rate limit
+	assert.True(at[2].Sub(at[1]) >= window*9/10)
+}
+
+func (t *ChatTests) TestChatDispatcherStale() {
+	assert := assert.New(t.Test)
+
+	for _, merge := range []bool{true, false} {
+		started := make(chan bool, 1)
+		release := make(chan bool)
+		client, sent := blockingChatClient(started, release)
+
+		var (
+			mu      sync.Mutex
+			results = map[string]*ChatResult{}
+		)
+		dispatcher := client.NewChatDispatcher(&ChatDispatcherOptions{
+			MaxAge:     time.Millisecond,
+			MergeStale: merge,
+			OnResult: func(result *ChatResult) {
+				mu.Lock()
+				results[result.Text] = result
+				mu.Unlock()
+			},
+		})
+
+		dispatcher.Send(stubChannelID, "first", ChatPriorityNormal)
+		<-started
+		a, _ := dispatcher.Send(stubChannelID, "a", ChatPriorityNormal)
+		dispatcher.Send(stubChannelID, "b", ChatPriorityNormal)
+		time.Sleep(5 * time.Millisecond)
+		close(release)
+		dispatcher.Close()
+
+		messages, _ := sent()
+		if merge {
+			assert.EqualValues([]string{"first", "a | b"}, messages)
+			assert.EqualValues(ChatSent, results["a | b"].Status)
+			assert.EqualValues(ChatMerged, results["b"].Status)
+			assert.EqualValues(a, results["b"].MergedInto)
+			continue
+		}
+
+		assert.EqualValues([]string{"first"}, messages)
+		assert.EqualValues(ChatDropped, results["a"].Status)
+		assert.EqualValues(ChatDropped, results["b"].Status)
+	}
+}
+
+func (t *ChatTests) TestChatCatalogRender() {
+	assert := assert.New(t.Test)
+
+	catalog := NewChatCatalog("en")
+	assert.NoError(catalog.AddMessages("en", map[string]string{
+		"winners": `{{.Count}} {{plural .Count "one" "winner" "other" "winners"}} in {{.Name}}`,
+		"welcome": "Welcome {{.Name}}!",
+	}))
+	assert.NoError(catalog.AddMessages("pl", map[string]string{
+		"winners": `{{.Count}} {{plural .Count "one" "zwycięzca" "few" "zwycięzców" "other" "zwycięzcy"}}`,
+	}))
+	catalog.SetPluralRule("pl", func(n float64) string {
+		if n == 1 {
+			return PluralOne
+		}
+		if n >= 2 && n <= 4 {
+			return PluralFew
+		}
+		return PluralOther
+	})
+
+	message, err := catalog.Render("en", "winners", map[string]interface{}{"Count": 1, "Name": "chat"})
+	assert.NoError(err)
+	assert.EqualValues("1 winner in chat", message)
+
+	message, err = catalog.Render("en-GB", "winners", map[string]interface{}{"Count": 3, "Name": "chat"})
+	assert.NoError(err)
+	assert.EqualValues("3 winners in chat", message)
+
+	message, err = catalog.Render("pl_PL", "winners", map[string]interface{}{"Count": 3})
+	assert.NoError(err)
+	assert.EqualValues("3 zwycięzców", message)
+
+	message, err = catalog.Render("pl", "winners", map[string]interface{}{"Count": 7})
+	assert.NoError(err)
+	assert.EqualValues("7 zwycięzcy", message)
+
+	// falls back to the catalog locale
+	message, err = catalog.Render("pl", "welcome", map[string]interface{}{"Name": "ü"})
+	assert.NoError(err)
+	assert.EqualValues("Welcome ü!", message)
+
+	_, err = catalog.Render("en", "missing", nil)
+	assert.EqualError(err, "missing chat template key:missing locale:en")
+
+	_, err = catalog.Render("en", "welcome", map[string]interface{}{})
+	assert.Error(err)
+
+	assert.Error(catalog.Add("en", "broken", "{{.Name"))
+
+	_, err = catalog.Render("en", "welcome", map[string]interface{}{"Name": strings.Repeat("é", maxMessageSize)})
+	assert.Contains(err.Error(), "exceeds 280 character limit")
+}
+
+func (t *ChatTests) TestSendTemplatedChatMessage() {
+	assert := assert.New(t.Test)
+
+	var sent []string
+	client := newStubClient(func(req *http.Request) *http.Response {
+		var msg chatMessage
+		json.NewDecoder(req.Body).Decode(&msg)
+		sent = append(sent, msg.Text)
+
+		return stubResponse(http.StatusNoContent, "")
+	})
+
+	catalog := NewChatCatalog("en")
+	assert.NoError(catalog.Add("en", "welcome", "Welcome {{.}}!"))
+	assert.NoError(catalog.Add("fr", "welcome", "Bienvenue {{.}} !"))
+
+	_, err := client.SendTemplatedChatMessage(stubChannelID, catalog, "fr-CA", "welcome", "viewer")
+	assert.NoError(err)
+
+	_, err = client.SendTemplatedChatMessage(stubChannelID, catalog, "fr", "welcome", strings.Repeat("x", maxMessageSize))
+	assert.Error(err)
+
+	assert.EqualValues([]string{"Bienvenue viewer !"}, sent)
+}
+
+func (t *ChatTests) TestFilterChatMessage() {
+	assert := assert.New(t.Test)
+
+	var blocked *ChatBlockedError
+
+	_, err := FilterChatMessage("Vote for Bad Word!", DenyListFilter("bad word", "nope"))
+	assert.ErrorAs(err, &blocked)
+	assert.EqualValues(ChatBlockedDenyList, blocked.Reason)
+	assert.EqualValues("Vote for Bad Word!", blocked.Message)
+
+	message, err := FilterChatMessage("badwords and nopes", DenyListFilter("bad word", "nope"))
+	assert.NoError(err)
+	assert.EqualValues("badwords and nopes", message)
+
+	message, err = FilterChatMessage(
+		"see https://example.com/a?b=c or www.test.org and clips.twitch.tv/x",
+		StripURLsFilter("[link]"),
+	)
+	assert.NoError(err)
+	assert.EqualValues("see [link] or [link] and [link]", message)
+
+	message, err = FilterChatMessage("a\u200bb\u202ec\x00d\ne", StripControlCharactersFilter())
+	assert.NoError(err)
+	assert.EqualValues("abcd e", message)
+
+	message, err = FilterChatMessage("@streamer hi @@mod, mail me@example", NeutraliseMentionsFilter())
+	assert.NoError(err)
+	assert.EqualValues("streamer hi mod, mail me@example", message)
+
+	_, err = FilterChatMessage("https://example.com", StripURLsFilter(""))
+	assert.ErrorAs(err, &blocked)
+	assert.EqualValues(ChatBlockedEmpty, blocked.Reason)
+
+	rejected := fmt.Errorf("flagged by moderation")
+	_, err = FilterChatMessage("hello", func(message string) (string, error) {
+		return "", rejected
+	})
+	assert.ErrorAs(err, &blocked)
+	assert.ErrorIs(err, rejected)
+	assert.EqualValues(ChatBlockedModeration, blocked.Reason)
+}
+
+func (t *ChatTests) TestSendTwitchChatMessageFiltered() {
+	assert := assert.New(t.Test)
+
+	var sent []string
+	client := newStubClient(func(req *http.Request) *http.Response {
+		var msg chatMessage
+		json.NewDecoder(req.Body).Decode(&msg)
+		sent = append(sent, msg.Text)
+
+		return stubResponse(http.StatusNoContent, "")
+	}, &Options{
+		ChatFilters: []ChatFilter{
+			StripControlCharactersFilter(),
+			NeutraliseMentionsFilter(),
+			DenyListFilter("blocked"),
+		},
+	})
+
+	_, err := client.SendTwitchChatMessage(stubChannelID, "poll winner:\n@viewer")
+	assert.NoError(err)
+
+	var blocked *ChatBlockedError
+	_, err = client.SendTwitchChatMessage(stubChannelID, "BLOCKED option")
+	assert.ErrorAs(err, &blocked)
+
+	assert.EqualValues([]string{"poll winner: viewer"}, sent)
+}
+
+func (t *LiveChannelTests) TestGetLiveChannelsWithExtensionEnabled() {
+	assert := assert.New(t.Test)
+
+	var query url.Values
+	helix := newStubClient(func(req *http.Request) *http.Response {
+		assert.EqualValues("/helix/extensions/live", req.URL.Path)
+		query = req.URL.Query()
+		return stubResponse(http.StatusOK, `{
+			"data": [{
+				"broadcaster_id": "252766116",
+				"broadcaster_name": "swoosh_xii",
+				"game_name": "Rainbow Six Siege",
+				"game_id": "460630",
+				"title": "ranked"
+			}],
+			"pagination": "next"
+		}`)
+	}, &Options{AppAccessToken: "token"})
+
+	channels, err := helix.GetLiveChannelsWithExtensionEnabled(twitchPkg.ClientID, "after")
+	assert.NoError(err)
+	assert.EqualValues("next", channels.Bookmark)
+	assert.EqualValues(&Channel{
+		ID:       "252766116",
+		Username: "swoosh_xii",
+		GameID:   "460630",
+		Game:     "Rainbow Six Siege",
+		Title:    "ranked",
+	}, channels.Channels[0])
+	assert.EqualValues(twitchPkg.ClientID, query.Get("extension_id"))
+	assert.EqualValues("after", query.Get("after"))
+	assert.EqualValues("100", query.Get("first"))
+
+	legacy := newStubClient(func(req *http.Request) *http.Response {
+		assert.EqualValues("/extensions/"+twitchPkg.ClientID+"/live_activated_channels", req.URL.Path)
+		query = req.URL.Query()
+		return stubResponse(http.StatusOK, `{
+			"channels": [
+				{"game": "Art", "id": "1", "username": "a", "title": "painting", "view_count": "42"},
+				{"game": "Art", "id": "2", "username": "b", "title": "drawing", "view_count": 7}
+			],
+			"cursor": "next"
+		}`)
+	}, &Options{LiveChannelsAPI: LegacyAPI})
+
+	channels, err = legacy.GetLiveChannelsWithExtensionEnabled(twitchPkg.ClientID, "bookmark")
+	assert.NoError(err)
+	assert.EqualValues("next", channels.Bookmark)
+	assert.EqualValues("bookmark", query.Get("cursor"))
+	assert.EqualValues(&Channel{ID: "1", Username: "a", Game: "Art", Title: "painting", Viewers: 42}, channels.Channels[0])
+	assert.EqualValues(7, channels.Channels[1].Viewers)
+
+	var channel Channel
+	assert.Error(json.Unmarshal([]byte(`{"view_count":"many"}`), &channel))
+	assert.JSONEq(
+		`{"game":"Art","id":"1","username":"a","title":"painting","view_count":"42"}`,
+		utils.ToJSON(channels.Channels[0]),
+	)
+	assert.NoError(json.Unmarshal([]byte(utils.ToJSON(channels.Channels[0])), &channel))
+	assert.EqualValues(*channels.Channels[0], channel)
+}
+
+func (t *LiveChannelTests) TestGetLiveChannelsAuth() {
+	assert := assert.New(t.Test)
+
+	var (
+		authorization string
+		extensionID   string
+		tokens        int
+	)
+	stub := func(req *http.Request) *http.Response {
+		if req.URL.Host == "id.twitch.tv" {
+			tokens++
+			req.ParseForm()
+			assert.EqualValues(twitchPkg.ClientID, req.Form.Get("client_id"))
+			assert.EqualValues("client-secret", req.Form.Get("client_secret"))
+			assert.EqualValues("client_credentials", req.Form.Get("grant_type"))
+			return stubResponse(http.StatusOK, fmt.Sprintf(`{"access_token":"app-%d","expires_in":3600}`, tokens))
+		}
+
+		authorization = req.Header.Get("Authorization")
+		extensionID = req.URL.Query().Get("extension_id")
+		if authorization == "Bearer app-1" && tokens == 1 {
+			// the first token is revoked
+			return stubResponse(http.StatusUnauthorized, "")
+		}
+		return stubResponse(http.StatusOK, `{"data":[],"pagination":""}`)
+	}
+
+	_, err := newStubClient(stub, &Options{LiveChannelsAPI: HelixAPI}).GetLiveChannels("")
+	assert.ErrorIs(err, ErrMissingAppAccessToken)
+
+	_, err = newStubClient(stub, &Options{AppAccessToken: "static"}).GetLiveChannels("")
+	assert.NoError(err)
+	assert.EqualValues("Bearer static", authorization)
+	assert.EqualValues(twitchPkg.ClientID, extensionID)
+
+	client := newStubClient(stub, &Options{ClientSecret: "client-secret"})
+	_, err = client.GetLiveChannelsWithExtensionEnabled("", "")
+	assert.NoError(err)
+	assert.EqualValues("Bearer app-2", authorization)
+	assert.EqualValues(twitchPkg.ClientID, extensionID)
+
+	_, err = client.GetLiveChannelsWithExtensionEnabled("other-extension", "")
+	assert.NoError(err)
+	assert.EqualValues("Bearer app-2", authorization)
+	assert.EqualValues("other-extension", extensionID)
+	assert.EqualValues(2, tokens)
+
+	var apiErr *APIError
+	unauthorized := newStubClient(func(req *http.Request) *http.Response {
+		return stubResponse(http.StatusUnauthorized, "")
+	}, &Options{AppAccessToken: "static"})
+	_, err = unauthorized.GetLiveChannels("")
+	assert.ErrorAs(err, &apiErr)
+	assert.EqualValues(http.StatusUnauthorized, apiErr.StatusCode)
+
+	// without app access token credentials the legacy endpoint is the default
+	legacy := newStubClient(func(req *http.Request) *http.Response {
+		authorization = req.Header.Get("Authorization")
+		assert.EqualValues(twitchPkg.ClientID, req.Header.Get("Client-ID"))
+		assert.EqualValues("/extensions/"+twitchPkg.ClientID+"/live_activated_channels", req.URL.Path)
+		return stubResponse(http.StatusOK, `{"channels":[],"cursor":""}`)
+	})
+	_, err = legacy.GetLiveChannels("")
+	assert.NoError(err)
+	assert.Empty(authorization)
+}
+
+// liveChannelsClient stubs the live channels endpoint with three pages
+// of two channels, rate limiting the first request for the second page.
+// Streams report ten viewers per channel and are not counted as requests.
+func liveChannelsClient() (*Twitch, func() int) {
+	var (
+		mu       sync.Mutex
+		requests int
+		limited  bool
+	)
+	pages := map[string]string{
+		"":  `{"data":[{"broadcaster_id":"1"},{"broadcaster_id":"2"}],"pagination":"b"}`,
+		"b": `{"data":[{"broadcaster_id":"3"},{"broadcaster_id":"4"}],"pagination":"c"}`,
+		"c": `{"data":[{"broadcaster_id":"5"},{"broadcaster_id":"6"}],"pagination":""}`,
+	}
+
+	client := newStubClient(func(req *http.Request) *http.Response {
+		if req.URL.Path == "/helix/streams" {
+			return stubStreams(req, 10)
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		requests++
+
+		cursor := req.URL.Query().Get("after")
+		if cursor == "b" && !limited {
+			limited = true
+			res := stubResponse(http.StatusTooManyRequests, "")
+			res.Header.Set("Ratelimit-Reset", strconv.FormatInt(time.Now().Unix(), 10))
+			return res
+		}
+		return stubResponse(http.StatusOK, pages[cursor])
+	}, &Options{AppAccessToken: "token"})
+
+	return client, func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return requests
+	}
+}
+
+// stubStreams responds to a Get Streams request
+// with the viewers for every requested channel.
+func stubStreams(req *http.Request, viewers int) *http.Response {
+	var streams []map[string]interface{}
+	for _, id := range req.URL.Query()["user_id"] {
+		streams = append(streams, map[string]interface{}{"user_id": id, "viewer_count": viewers})
+	}
+	return stubResponse(http.StatusOK, utils.ToJSON(map[string]interface{}{"data": streams}))
+}
+
+func channelIDs(channels []*Channel) (ids []string) {
+	for _, channel := range channels {
+		ids = append(ids, channel.ID)
+	}
+	return
+}
+
+func (t *LiveChannelTests) TestEachLiveChannel() {
+	assert := assert.New(t.Test)
+
+	client, requests := liveChannelsClient()
+
+	var ids []string
+	err := client.EachLiveChannel(context.Background(), nil, func(channel *Channel) error {
+		ids = append(ids, channel.ID)
+		if channel.ID == "4" {
+			return ErrStopIteration
+		}
+		return nil
+	})
+	assert.NoError(err)
+	assert.EqualValues([]string{"1", "2", "3", "4"}, ids)
+	assert.EqualValues(3, requests())
+
+	client, _ = liveChannelsClient()
+	failed := fmt.Errorf("failed")
+	err = client.EachLiveChannel(context.Background(), nil, func(channel *Channel) error {
+		return failed
+	})
+	assert.ErrorIs(err, failed)
+
+	limitedRequests := 0
+	limited := newStubClient(func(req *http.Request) *http.Response {
+		limitedRequests++
+		return stubResponse(http.StatusTooManyRequests, "")
+	}, &Options{AppAccessToken: "token"})
+	var rateLimited *RateLimitError
+	err = limited.EachLiveChannel(
+		context.Background(),
+		&LiveChannelsOptions{RateLimitRetries: 1, RetryWait: time.Millisecond},
+		func(channel *Channel) error { return nil },
+	)
+	assert.ErrorAs(err, &rateLimited)
+	assert.EqualValues(2, limitedRequests)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	client, requestCount := liveChannelsClient()
+	err = client.EachLiveChannel(ctx, nil, func(channel *Channel) error {
+		return nil
+	})
+	assert.ErrorIs(err, context.Canceled)
+	assert.EqualValues(0, requestCount())
+}
+
+func (t *LiveChannelTests) TestCollectLiveChannels() {
+	assert := assert.New(t.Test)
+
+	client, _ := liveChannelsClient()
+	channels, err := client.CollectLiveChannels(context.Background(), nil)
+	assert.NoError(err)
+	assert.EqualValues([]string{"1", "2", "3", "4", "5", "6"}, channelIDs(channels))
+
+	assert.EqualValues(0, channels[0].Viewers)
+
+	client, requests := liveChannelsClient()
+	channels, err = client.CollectLiveChannels(context.Background(), &LiveChannelsOptions{MaxResults: 3})
+	assert.NoError(err)
+	assert.EqualValues([]string{"1", "2", "3"}, channelIDs(channels))
+	assert.EqualValues(3, requests())
+
+	client, _ = liveChannelsClient()
+	channels, err = client.CollectLiveChannels(context.Background(), &LiveChannelsOptions{IncludeViewers: true})
+	assert.NoError(err)
+	require.Len(t.Test, channels, 6)
+	for _, channel := range channels {
+		assert.EqualValues(10, channel.Viewers)
+	}
+}
+
+func (t *LiveChannelTests) TestChannelWatcher() {
+	assert := assert.New(t.Test)
+
+	var (
+		mu    sync.Mutex
+		polls int
+	)
+	// the second poll fails and must not report channels offline
+	responses := []struct {
+		code int
+		body string
+	}{
+		{http.StatusOK, `{"data":[{"broadcaster_id":"a","title":"one"},{"broadcaster_id":"b","title":"two"}]}`},
+		{http.StatusInternalServerError, ""},
+		{http.StatusOK, `{"data":[{"broadcaster_id":"b","title":"three","game_name":"Art"},{"broadcaster_id":"c"}]}`},
+	}
+	client := newStubClient(func(req *http.Request) *http.Response {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if req.URL.Path == "/helix/streams" {
+			// viewers change between the first and last polls
+			return stubStreams(req, polls)
+		}
+
+		response := responses[len(responses)-1]
+		if polls < len(responses) {
+			response = responses[polls]
+		}
+		polls++
+		return stubResponse(response.code, response.body)
+	}, &Options{AppAccessToken: "token"})
+
+	events := make(chan *ChannelEvent)
+	var errs int32
+	watcher := client.NewChannelWatcher(&ChannelWatcherOptions{
+		Interval:     5 * time.Millisecond,
+		LiveChannels: &LiveChannelsOptions{IncludeViewers: true},
+		Events:       events,
+		OnError: func(err error) {
+			mu.Lock()
+			errs++
+			mu.Unlock()
+		},
+	})
+
+	var received []*ChannelEvent
+	for len(received) < 5 {
+		select {
+		case event := <-events:
+			received = append(received, event)
+		case <-time.After(time.Second):
+			t.Test.Fatal("timed out waiting for channel events")
+		}
+	}
+	watcher.Close()
+
+	assert.EqualValues(ChannelLive, received[0].Type)
+	assert.EqualValues("a", received[0].ChannelID)
+	assert.EqualValues(ChannelLive, received[1].Type)
+	assert.EqualValues("b", received[1].ChannelID)
+
+	assert.EqualValues(ChannelOffline, received[2].Type)
+	assert.EqualValues("a", received[2].ChannelID)
+	assert.Nil(received[2].Channel)
+	assert.EqualValues(ChannelUpdated, received[3].Type)
+	assert.EqualValues([]ChannelChange{ChannelTitleChanged, ChannelGameChanged, ChannelViewersChanged}, received[3].Changes)
+	assert.EqualValues("two", received[3].Previous.Title)
+	assert.EqualValues(ChannelLive, received[4].Type)
+	assert.EqualValues("c", received[4].ChannelID)
+
+	assert.EqualValues([]string{"b", "c"}, channelIDs(watcher.Channels()))
+	mu.Lock()
+	assert.EqualValues(1, errs)
+	mu.Unlock()
+}
+
+func (t *LiveChannelTests) TestLiveAggregator() {
+	assert := assert.New(t.Test)
+
+	start := time.Date(2021, 1, 1, 12, 0, 0, 0, time.UTC)
+	aggregator := NewLiveAggregator(&LiveAggregatorOptions{Retention: time.Hour})
+
+	aggregator.Add(start.Add(-2*time.Hour), []*Channel{{ID: "expired", Viewers: 1000}})
+	aggregator.Add(start.Add(time.Minute), []*Channel{
+		{ID: "a", Game: "Art", GameID: "1", Viewers: 10},
+		{ID: "b", Game: "Chess", GameID: "2", Viewers: 50},
+		{ID: "c", Game: "Art", GameID: "1", Viewers: 5},
+	})
+	aggregator.Add(start, []*Channel{
+		{ID: "a", Game: "Art", GameID: "1", Viewers: 20},
+		{ID: "a", Game: "Art", GameID: "1", Viewers: 20},
+	})
+
+	summary := aggregator.Summary(time.Time{}, time.Time{})
+	assert.EqualValues(2, summary.Snapshots)
+	assert.EqualValues(start, summary.From)
+	assert.EqualValues(start.Add(time.Minute), summary.To)
+	assert.EqualValues(3, summary.UniqueChannels)
+	assert.EqualValues(3, summary.PeakChannels)
+	assert.EqualValues(65, summary.PeakViewers)
+	assert.EqualValues(start.Add(time.Minute), summary.PeakViewersAt)
+	assert.EqualValues(2, summary.AverageChannels)
+	assert.EqualValues(42.5, summary.AverageViewers)
+	assert.EqualValues([]*LiveSnapshotPoint{
+		{At: start, Channels: 1, Viewers: 20},
+		{At: start.Add(time.Minute), Channels: 3, Viewers: 65},
+	}, summary.Series)
+	assert.EqualValues([]*GameSummary{
+		{Game: "Art", GameID: "1", UniqueChannels: 2, PeakChannels: 2, PeakViewers: 20},
+		{Game: "Chess", GameID: "2", UniqueChannels: 1, PeakChannels: 1, PeakViewers: 50},
+	}, summary.Games)
+
+	ranged := aggregator.Summary(start.Add(time.Second), time.Time{})
+	assert.EqualValues(1, ranged.Snapshots)
+	assert.EqualValues(0, aggregator.Summary(start.Add(time.Hour), time.Time{}).Snapshots)
+
+	buf := &strings.Builder{}
+	assert.NoError(summary.WriteCSV(buf))
+	assert.EqualValues("at,channels,viewers\n2021-01-01T12:00:00Z,1,20\n2021-01-01T12:01:00Z,3,65\n", buf.String())
+
+	buf.Reset()
+	assert.NoError(summary.WriteGamesCSV(buf))
+	assert.EqualValues("game,game_id,unique_channels,peak_channels,peak_viewers\nArt,1,2,2,20\nChess,2,1,1,50\n", buf.String())
+
+	buf.Reset()
+	assert.NoError(summary.WriteJSON(buf))
+	var decoded LiveSummary
+	assert.NoError(json.Unmarshal([]byte(buf.String()), &decoded))
+	assert.EqualValues(summary.PeakViewers, decoded.PeakViewers)
+	assert.EqualValues(summary.Games, decoded.Games)
+}
+
+func (t *LiveChannelTests) TestSnapshotLiveChannels() {
+	assert := assert.New(t.Test)
+
+	client, _ := liveChannelsClient()
+	aggregator := NewLiveAggregator()
+
+	assert.NoError(client.SnapshotLiveChannels(context.Background(), aggregator, &LiveChannelsOptions{MaxResults: 1}))
+
+	summary := aggregator.Summary(time.Time{}, time.Time{})
+	assert.EqualValues(1, summary.Snapshots)
+	assert.EqualValues(6, summary.UniqueChannels)
+	assert.EqualValues(60, summary.PeakViewers)
+}
+
 //func (t *ConfigurationTests) TestSetGlobalSegment() {
 //	assert := assert.New(t.Test)
 //
@@ -234,6 +2139,8 @@ func (t *JWTTests) TestJWTVerify() {
 //	assert.NoError(err)
 //	assert.NotEmpty(configs)
 //	assert.EqualValues(2, len(configs.Configurations))
+//	assert.NotNil(configs.Broadcaster)
+//	assert.NotNil(configs.Developer)
 //}
 
 //func (t *ConfigurationTests) TestSetExtensionRequired() {